@@ -21,35 +21,137 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 )
 
+type identity struct {
+	RandomNumber  int    `json:"totally-random-number-without-any-meaning"`
+	ApplicationID string `json:"application_id"`
+	InstanceIndex int    `json:"instance_index"`
+}
+
 func main() {
-	go spamLog(time.Second)
+	go spamLog(time.Second, logStream())
+
+	port := os.Getenv("PORT")
+	if port == "" {
+		port = "8080"
+	}
+
+	self := readIdentity()
+
+	switch mode := os.Getenv("WATCHFUL_MODE"); mode {
+	case "tcp-echo":
+		fmt.Println("Starting watchful sample app for merkhet in tcp echo mode!")
+		if err := serveEcho(":" + port); err != nil {
+			log.Fatal(err)
+		}
+
+	case "", "http":
+		fmt.Println("Starting watchful sample app for merkhet!")
+		http.HandleFunc("/", func(out http.ResponseWriter, in *http.Request) {
+			renderIndexPage(out, self)
+		})
+		http.HandleFunc("/stream", streamHeartbeats)
+		if err := http.ListenAndServe(":"+port, nil); err != nil {
+			log.Fatal(err)
+		}
+
+	default:
+		log.Fatalf("unknown mode %s", mode)
+	}
+}
+
+func readIdentity() identity {
+	self := identity{RandomNumber: 949207500}
+
+	var application struct {
+		ApplicationID string `json:"application_id"`
+	}
+	if err := json.Unmarshal([]byte(os.Getenv("VCAP_APPLICATION")), &application); err == nil {
+		self.ApplicationID = application.ApplicationID
+	}
 
-	fmt.Println("Starting watchful sample app for merkhet!")
-	http.HandleFunc("/", renderIndexPage)
-	if err := http.ListenAndServe(":8080", nil); err != nil {
-		log.Fatal(err)
+	if index, err := strconv.Atoi(os.Getenv("CF_INSTANCE_INDEX")); err == nil {
+		self.InstanceIndex = index
 	}
+	return self
 }
 
-func renderIndexPage(out http.ResponseWriter, in *http.Request) {
+func renderIndexPage(out http.ResponseWriter, self identity) {
 	out.Header().Add("content-type", "application/json")
 	out.WriteHeader(200)
 
-	_, _ = fmt.Fprint(out, `{"totally-random-number-without-any-meaning":949207500}`)
+	_ = json.NewEncoder(out).Encode(self)
 }
 
-func spamLog(iteration time.Duration) {
-	ticker := time.NewTicker(iteration)
+func streamHeartbeats(out http.ResponseWriter, in *http.Request) {
+	flusher, ok := out.(http.Flusher)
+	if !ok {
+		http.Error(out, "streaming is not supported", http.StatusInternalServerError)
+		return
+	}
+
+	out.Header().Add("content-type", "text/plain")
+	out.WriteHeader(200)
+	flusher.Flush()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case t := <-ticker.C:
+			if _, err := fmt.Fprintf(out, "Heartbeat{%d}\n", t.Unix()); err != nil {
+				return
+			}
+			flusher.Flush()
+
+		case <-in.Context().Done():
+			return
+		}
+	}
+}
+
+func serveEcho(address string) error {
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
 	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			return err
+		}
+
+		go func() {
+			defer connection.Close()
+			_, _ = io.Copy(connection, connection)
+		}()
+	}
+}
+
+func logStream() string {
+	if stream := os.Getenv("CF_INSTANCE_GUID"); stream != "" {
+		return stream
+	}
+	return strconv.FormatInt(time.Now().UnixNano(), 10)
+}
+
+func spamLog(iteration time.Duration, stream string) {
+	ticker := time.NewTicker(iteration)
+	for sequence := 1; ; sequence++ {
 		select {
 		case t := <-ticker.C:
-			fmt.Printf("Timestamp{%d}\n", t.Unix())
+			fmt.Printf("Timestamp{%d} Sequence{%s:%d}\n", t.Unix(), stream, sequence)
 		}
 	}
 }
@@ -0,0 +1,88 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cfg
+
+import (
+	"gopkg.in/yaml.v3"
+)
+
+// Locator looks up the line numbers of the nodes of a yaml or json configuration
+type Locator struct {
+	root *yaml.Node
+}
+
+// NewLocator creates a new locator for the given configuration content
+func NewLocator(content []byte) (*Locator, error) {
+	document := &yaml.Node{}
+	if err := yaml.Unmarshal(content, document); err != nil {
+		return nil, err
+	}
+
+	if document.Kind == yaml.DocumentNode && len(document.Content) > 0 {
+		return &Locator{root: document.Content[0]}, nil
+	}
+	return &Locator{root: document}, nil
+}
+
+// Line returns the line of the node found under the given path. A path element is either the string key of a
+// mapping or the int index of a sequence. If the path cannot be resolved completely, the line of the deepest
+// node found is returned. A line of 0 means the line is unknown
+func (l *Locator) Line(path ...interface{}) int {
+	if l == nil || l.root == nil {
+		return 0
+	}
+
+	node := l.root
+	line := node.Line
+	for _, element := range path {
+		switch key := element.(type) {
+		case string:
+			if node.Kind != yaml.MappingNode {
+				return line
+			}
+
+			found := false
+			for i := 0; i+1 < len(node.Content); i += 2 {
+				if node.Content[i].Value == key {
+					line = node.Content[i].Line
+					node = node.Content[i+1]
+					found = true
+					break
+				}
+			}
+
+			if !found {
+				return line
+			}
+		case int:
+			if node.Kind != yaml.SequenceNode || key < 0 || key >= len(node.Content) {
+				return line
+			}
+
+			node = node.Content[key]
+			line = node.Line
+		default:
+			return line
+		}
+	}
+
+	return line
+}
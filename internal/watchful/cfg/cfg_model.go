@@ -52,9 +52,13 @@ type TaskConfiguration struct {
 
 // MerkhetConfiguration is the configuration of one merkhet instance running
 type MerkhetConfiguration struct {
-	Name          string         `yaml:"name"`
-	Threshold     string         `yaml:"threshold"`
-	HeartbeatRate *time.Duration `yaml:"heartbeat"`
+	Name             string                 `yaml:"name"`
+	Type             string                 `yaml:"type"`
+	Threshold        string                 `yaml:"threshold"`
+	MaxTotalDowntime *time.Duration         `yaml:"max-total-downtime"`
+	LatencyThreshold string                 `yaml:"latency-threshold"`
+	HeartbeatRate    *time.Duration         `yaml:"heartbeat"`
+	Settings         map[string]interface{} `yaml:"settings"`
 }
 
 // LoggerConfiguration is the config for the logger system watchful uses
@@ -63,6 +67,15 @@ type LoggerConfiguration struct {
 	PrintLoggerName bool   `yaml:"print-logger-name"`
 }
 
+// GetType returns the merkhet implementation the configuration targets. If no type is configured, the name of the
+// configuration is used as its type
+func (m MerkhetConfiguration) GetType() string {
+	if len(m.Type) < 1 {
+		return m.Name
+	}
+	return m.Type
+}
+
 // GetHeartbeatRate returns the rate in which the heart of the merkhet beats
 func (m MerkhetConfiguration) GetHeartbeatRate(defaultValue time.Duration) time.Duration {
 	if m.HeartbeatRate == nil {
@@ -21,6 +21,8 @@
 package cfg_test
 
 import (
+	"io/ioutil"
+
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
@@ -43,6 +45,7 @@ var _ = Describe("Configuration testing", func() {
 
 			Expect(config.CloudFoundryConfig.SkipSSLValidation).To(BeTrue())
 			Expect(config.CloudFoundryConfig.Domain).To(BeEquivalentTo("file-yaml-test.com"))
+			Expect(config.MerkhetConfigurations[4].Settings).To(HaveKeyWithValue("drain-url", "syslog://watchful.file-yaml-test.com:5514"))
 		})
 
 		It("Should read the config from a string yaml", func() {
@@ -66,11 +69,28 @@ logger-config:
 			Expect(config.LoggerConfiguration.PrintLoggerName).To(BeTrue())
 		})
 
+		It("Should default the merkhet type to its name", func() {
+			var body = `---
+merkhets:
+- name: http-availability
+  threshold: '1'
+- name: http-availability-tcp-router
+  type: http-availability
+  threshold: 5%`
+
+			Expect(cfg.ParseFromString(body, &config)).To(BeNil())
+
+			Expect(config.MerkhetConfigurations[0].GetType()).To(BeEquivalentTo("http-availability"))
+			Expect(config.MerkhetConfigurations[1].Name).To(BeEquivalentTo("http-availability-tcp-router"))
+			Expect(config.MerkhetConfigurations[1].GetType()).To(BeEquivalentTo("http-availability"))
+		})
+
 		It("Should read the config from the model json file", func() {
 			Expect(cfg.ParseFromFile("./test_config.json", &config)).To(BeNil())
 
 			Expect(config.CloudFoundryConfig.SkipSSLValidation).To(BeTrue())
 			Expect(config.CloudFoundryConfig.Domain).To(BeEquivalentTo("file-json-test.com"))
+			Expect(config.MerkhetConfigurations[4].Settings).To(HaveKeyWithValue("listen-address", ":5514"))
 		})
 
 		It("Should read the config from a string json", func() {
@@ -81,5 +101,18 @@ logger-config:
 			Expect(config.CloudFoundryConfig.SkipSSLValidation).To(BeTrue())
 			Expect(config.CloudFoundryConfig.Domain).To(BeEquivalentTo("string-json-test.com"))
 		})
+
+		It("Should locate the lines of configuration nodes", func() {
+			content, err := ioutil.ReadFile("./test_config.yml")
+			Expect(err).To(BeNil())
+
+			locator, err := cfg.NewLocator(content)
+			Expect(err).To(BeNil())
+
+			Expect(locator.Line("cf", "domain")).To(BeEquivalentTo(3))
+			Expect(locator.Line("merkhets", 1, "threshold")).To(BeEquivalentTo(27))
+			Expect(locator.Line("merkhets", 1, "heartbeat")).To(BeEquivalentTo(26))
+			Expect(locator.Line("merkhets", 42)).To(BeEquivalentTo(23))
+		})
 	})
 })
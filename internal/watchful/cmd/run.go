@@ -41,6 +41,15 @@ var (
 
 	// Verbose defines if the program will output debug information
 	Verbose bool
+
+	// ReportFile is the file the machine-readable report of the run is written to
+	ReportFile string
+
+	// JUnitFile is the file the JUnit XML report of the merkhet verdicts is written to
+	JUnitFile string
+
+	// MetricsAddress is the address the prometheus metrics endpoint listens on
+	MetricsAddress string
 )
 
 // runCmd is the run command definition using cobra
@@ -58,6 +67,9 @@ func run(cmd *cobra.Command, args []string) {
 		ConfigContent:           ConfigContent,
 		PushedAppSampleLanguage: PushedAppSampleLanguage,
 		Verbose:                 Verbose,
+		ReportFile:              ReportFile,
+		JUnitFile:               JUnitFile,
+		MetricsAddress:          MetricsAddress,
 	}
 
 	if err := e.Execute(); err != nil {
@@ -75,5 +87,11 @@ func init() {
 	runCmd.PersistentFlags().StringVarP(&PushedAppSampleLanguage, "language", "l", "go", "Defines in which language "+
 		"the push sample app should be written")
 	runCmd.PersistentFlags().BoolVarP(&Verbose, "verbose", "v", false, "Toggles whether the app is run in verbose mode")
+	runCmd.PersistentFlags().StringVarP(&ReportFile, "report", "r", "", "Writes a machine-readable report of the run "+
+		"to the given file, using json if the file ends with .json and yaml otherwise")
+	runCmd.PersistentFlags().StringVarP(&JUnitFile, "junit", "j", "", "Writes the merkhet verdicts of each task as "+
+		"JUnit XML to the given file")
+	runCmd.PersistentFlags().StringVarP(&MetricsAddress, "metrics-address", "m", "", "Serves prometheus metrics "+
+		"of the running merkhets under /metrics on the given address, eg: :9100")
 	rootCmd.AddCommand(runCmd)
 }
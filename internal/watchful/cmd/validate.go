@@ -0,0 +1,64 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/internal/watchful/services"
+	"github.com/spf13/cobra"
+)
+
+// ConfigFile is the path to the configuration file that is validated
+var ConfigFile string
+
+// validateCmd is the validate command definition using cobra
+var validateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validates a watchful configuration",
+	Long:  "Validate checks the provided configuration and reports all problems found at once without running watchful.",
+	Run:   validate,
+}
+
+// validate is the method called when someone uses the watchful validate command
+func validate(cmd *cobra.Command, args []string) {
+	content, err := ioutil.ReadFile(ConfigFile)
+	if err != nil {
+		fmt.Println(fmt.Sprintf("could not read config %s: %s", ConfigFile, err.Error()))
+		os.Exit(1)
+	}
+
+	if _, err := services.LoadConfiguration(content); err != nil {
+		fmt.Println(fmt.Sprintf("%s is not valid: %s", ConfigFile, err.Error()))
+		os.Exit(1)
+	}
+
+	_, _ = bunt.Printf("*%s* is SpringGreen{valid}\n", ConfigFile)
+}
+
+// init adds the validateCmd to the watchful root command
+func init() {
+	validateCmd.PersistentFlags().StringVarP(&ConfigFile, "config", "c", "config.yml", "Provides the path to the configuration file")
+	rootCmd.AddCommand(validateCmd)
+}
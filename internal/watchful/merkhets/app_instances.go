@@ -0,0 +1,60 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"bytes"
+	"time"
+
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+)
+
+// awaitAppInstances polls the instances of the app until they match the condition or the deadline is reached.
+// It returns the instances read last, or the error of the last poll if the instances could not be read at the deadline
+func awaitAppInstances(cli cfw.CloudFoundryCLI, l logger.Logger, appName string, deadline time.Time,
+	pollInterval time.Duration, condition func(current cfw.AppInstances) bool) (cfw.AppInstances, error) {
+	for {
+		current, err := readAppInstances(cli, l, appName, time.Until(deadline))
+		if err == nil && condition(current) {
+			return current, nil
+		}
+
+		if time.Now().Add(pollInterval).After(deadline) {
+			return current, err
+		}
+		time.Sleep(pollInterval)
+	}
+}
+
+// readAppInstances reads the current instances of the app, giving up after the timeout
+func readAppInstances(cli cfw.CloudFoundryCLI, l logger.Logger, appName string, timeout time.Duration) (cfw.AppInstances, error) {
+	if timeout <= 0 {
+		return cfw.AppInstances{}, cfw.ErrorCommandPromiseTimeout
+	}
+
+	output := &bytes.Buffer{}
+	errorLog := logger.NewByteBufferCachedLogger(l.ReportingOn(logger.Debug))
+	if err := cli.App(appName).SubscribeOnOut(output).SubscribeOnErr(errorLog).Timeout(timeout).Sync(); err != nil {
+		return cfw.AppInstances{}, err
+	}
+	return cfw.ParseAppInstances(output.String())
+}
@@ -0,0 +1,171 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var (
+	// DefaultCloudControllerEndpoints are the cheap read endpoints of the cloud controller requested by default
+	DefaultCloudControllerEndpoints = []string{"/v3/info", "/v3/apps?per_page=1"}
+)
+
+// CloudControllerSettings are the settings of the cf-api-availability merkhet
+type CloudControllerSettings struct {
+	Endpoints []string      `yaml:"endpoints"`
+	Timeout   time.Duration `yaml:"timeout"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "cf-api-availability",
+		DefaultHeartbeat: 5 * time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := CloudControllerSettings{Endpoints: DefaultCloudControllerEndpoints, Timeout: 30 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if len(settings.Endpoints) < 1 {
+				return nil, fmt.Errorf("at least one endpoint is required")
+			}
+
+			for _, endpoint := range settings.Endpoints {
+				if len(endpoint) < 1 || endpoint[0] != '/' {
+					return nil, fmt.Errorf("endpoint %q has to be a path starting with /", endpoint)
+				}
+			}
+
+			if settings.Timeout <= 0 {
+				return nil, fmt.Errorf("timeout %s has to be a positive duration", settings.Timeout.String())
+			}
+
+			return NewCloudControllerMerkhet(context.Dependencies.CLI, context.Base, settings.Endpoints, settings.Timeout), nil
+		},
+	})
+}
+
+// CloudControllerMerkhet is an implementation of the Merkhet interface that requests read endpoints of the cloud
+// controller api through the authenticated cli. Every endpoint is reported as a separate target
+type CloudControllerMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	BaseReference merkhet.Base
+	Endpoints     []string
+	Timeout       time.Duration
+}
+
+// NewCloudControllerMerkhet creates a new instance of the merkhet implementation to check the cloud controller api
+func NewCloudControllerMerkhet(cli cfw.CloudFoundryCLI, baseReference merkhet.Base, endpoints []string, timeout time.Duration) *CloudControllerMerkhet {
+	return &CloudControllerMerkhet{Cli: cli, BaseReference: baseReference, Endpoints: endpoints, Timeout: timeout}
+}
+
+// Install installs the merkhet, in this case does nothing
+func (m *CloudControllerMerkhet) Install() error {
+	return nil
+}
+
+// PostConnect post connects the merkhet, in this case does nothing as the cli is already authenticated
+func (m *CloudControllerMerkhet) PostConnect() error {
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected cf-api-merkhet")
+	return nil
+}
+
+// Execute requests every endpoint and returns the first failure
+func (m *CloudControllerMerkhet) Execute() error {
+	for _, sample := range m.ExecuteSamples() {
+		if sample.Failed() {
+			return fmt.Errorf("%s: %s", sample.Target, sample.Error)
+		}
+	}
+	return nil
+}
+
+// ExecuteSamples requests every endpoint one after another and returns a sample for each of them.
+// The endpoints are not requested concurrently, as the cli shares its configuration between all invocations
+func (m *CloudControllerMerkhet) ExecuteSamples() []merkhet.Sample {
+	samples := make([]merkhet.Sample, 0, len(m.Endpoints))
+	for _, endpoint := range m.Endpoints {
+		start := time.Now()
+		err := m.request(endpoint)
+		samples = append(samples, merkhet.NewTargetSample(endpoint, start, time.Since(start), err))
+	}
+	return samples
+}
+
+// request requests a single endpoint of the cloud controller
+func (m *CloudControllerMerkhet) request(endpoint string) error {
+	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+	response := &bytes.Buffer{}
+
+	if err := m.Cli.Curl(endpoint).SubscribeOnOut(response).SubscribeOnErr(errorLog).Timeout(m.Timeout).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("%s: Red{Failed to request the cloud controller: } %s", endpoint, err.Error()))
+		errorLog.Flush()
+		return err
+	}
+
+	if err := ParseCloudControllerResponse(response.Bytes()); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("%s: Red{Cloud controller responded with an error: } %s", endpoint, err.Error()))
+		return err
+	}
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("%s: SpringGreen{Requested cloud controller successfully}", endpoint))
+	return nil
+}
+
+// Base returns the base reference of the merkhet
+func (m *CloudControllerMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// ParseCloudControllerResponse checks that the response of the cloud controller is a json object without errors.
+// Both the error list of the v3 api and the error code of the v2 api are detected
+func ParseCloudControllerResponse(content []byte) error {
+	response := struct {
+		Errors []struct {
+			Title  string `json:"title"`
+			Detail string `json:"detail"`
+		} `json:"errors"`
+		ErrorCode   string `json:"error_code"`
+		Description string `json:"description"`
+	}{}
+
+	if err := json.Unmarshal(content, &response); err != nil {
+		return fmt.Errorf("the response is not a json object: %s", err.Error())
+	}
+
+	if len(response.Errors) > 0 {
+		return fmt.Errorf("%s: %s", response.Errors[0].Title, response.Errors[0].Detail)
+	}
+
+	if len(response.ErrorCode) > 0 {
+		return fmt.Errorf("%s: %s", response.ErrorCode, response.Description)
+	}
+	return nil
+}
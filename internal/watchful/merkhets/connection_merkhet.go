@@ -0,0 +1,328 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+// ConnectionSettings are the settings of the http-connection-stability merkhet
+type ConnectionSettings struct {
+	Connections       int           `yaml:"connections"`
+	IdleTimeout       time.Duration `yaml:"idle-timeout"`
+	ReconnectInterval time.Duration `yaml:"reconnect-interval"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "http-connection-stability",
+		DefaultHeartbeat: 10 * time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := ConnectionSettings{Connections: 3, IdleTimeout: 5 * time.Second, ReconnectInterval: time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.Connections < 1 {
+				return nil, fmt.Errorf("at least one connection is required")
+			}
+
+			if settings.IdleTimeout <= 0 || settings.ReconnectInterval <= 0 {
+				return nil, fmt.Errorf("idle-timeout and reconnect-interval have to be positive durations")
+			}
+
+			connectionMerkhet := NewConnectionMerkhet(context.Dependencies.Domain, context.Base, context.Dependencies.AppProvider,
+				settings.Connections, settings.IdleTimeout)
+			connectionMerkhet.ReconnectInterval = settings.ReconnectInterval
+			return connectionMerkhet, nil
+		},
+	})
+}
+
+// ConnectionMerkhet is an implementation of the Merkhet interface that keeps long-lived streaming connections to
+// the sample app open and records every unexpected disconnect. An execution fails if a connection dropped since
+// the previous execution or is still down
+type ConnectionMerkhet struct {
+	StreamURL         *string
+	BaseDomain        string
+	BaseReference     merkhet.Base
+	AppProvider       merkhet.AppProvider
+	HTTPClient        *http.Client
+	Connections       int
+	IdleTimeout       time.Duration
+	ReconnectInterval time.Duration
+
+	lock        *sync.Mutex
+	waitGroup   *sync.WaitGroup
+	cancel      context.CancelFunc
+	down        map[int]bool
+	disconnects []merkhet.Disconnect
+	reported    int
+	reconnected []int
+}
+
+// NewConnectionMerkhet creates a new instance of the merkhet implementation to check long-lived connections
+func NewConnectionMerkhet(baseDomain string, baseReference merkhet.Base, appProvider merkhet.AppProvider, connections int,
+	idleTimeout time.Duration) *ConnectionMerkhet {
+	down := make(map[int]bool)
+	for i := 0; i < connections; i++ {
+		down[i] = true
+	}
+
+	return &ConnectionMerkhet{
+		BaseDomain:        baseDomain,
+		BaseReference:     baseReference,
+		AppProvider:       appProvider,
+		HTTPClient:        &http.Client{},
+		Connections:       connections,
+		IdleTimeout:       idleTimeout,
+		ReconnectInterval: time.Second,
+		lock:              &sync.Mutex{},
+		waitGroup:         &sync.WaitGroup{},
+		down:              down,
+		disconnects:       make([]merkhet.Disconnect, 0),
+	}
+}
+
+// Install computes the url of the streaming endpoint of the sample app
+func (m *ConnectionMerkhet) Install() error {
+	parsedURL, e := url.Parse(m.BaseDomain)
+	if e != nil {
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not parse url from domain %s", m.BaseDomain))
+		return e
+	}
+
+	streamURL := parsedURL.Scheme + "://" + m.AppProvider.AppName() + "." + parsedURL.Host + "/stream"
+	m.StreamURL = &streamURL
+	return nil
+}
+
+// PostConnect pushes the sample app if not done and opens the connections to it
+func (m *ConnectionMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.Push(m.Base().Logger())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not post-connect upstream sample-app, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	m.Open()
+	m.Base().Logger().WriteString(logger.Info, fmt.Sprintf("Post-Connected connection-merkhet with %d connections", m.Connections))
+	return nil
+}
+
+// Execute checks that no connection dropped since the previous execution and that all connections are established
+func (m *ConnectionMerkhet) Execute() error {
+	_, err := m.check()
+	return err
+}
+
+// ExecuteSamples checks the connections and returns a sample containing the disconnects that were detected and the
+// disconnects that were reconnected since the last execution
+func (m *ConnectionMerkhet) ExecuteSamples() []merkhet.Sample {
+	start := time.Now()
+	disconnects, err := m.check()
+	sample := merkhet.NewSample(start, time.Since(start), err)
+	sample.Disconnects = disconnects
+	return []merkhet.Sample{sample}
+}
+
+// check fails if a connection dropped since the last execution or is still down
+func (m *ConnectionMerkhet) check() ([]merkhet.Disconnect, error) {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	dropped := len(m.disconnects) - m.reported
+	disconnects := append([]merkhet.Disconnect{}, m.disconnects[m.reported:]...)
+	for _, i := range m.reconnected {
+		if i < m.reported {
+			disconnects = append(disconnects, m.disconnects[i])
+		}
+	}
+	m.reported = len(m.disconnects)
+	m.reconnected = nil
+
+	if dropped == 0 && len(m.down) == 0 {
+		m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{All %d connections are established}", m.Connections))
+		return disconnects, nil
+	}
+
+	m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{%d connections dropped}, %d of %d connections are down",
+		dropped, len(m.down), m.Connections))
+	return disconnects, fmt.Errorf("%d connections dropped since the last heartbeat, %d of %d connections are down",
+		dropped, len(m.down), m.Connections)
+}
+
+// Base returns the base reference of the merkhet
+func (m *ConnectionMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// Open opens the connections to the streaming endpoint. Every connection is reopened until the merkhet is closed
+func (m *ConnectionMerkhet) Open() {
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+
+	m.lock.Lock()
+	for i := 0; i < m.Connections; i++ {
+		m.down[i] = true
+	}
+	m.lock.Unlock()
+
+	for i := 0; i < m.Connections; i++ {
+		m.waitGroup.Add(1)
+		go m.hold(ctx, i)
+	}
+}
+
+// Close closes all connections and waits until they are closed
+func (m *ConnectionMerkhet) Close() error {
+	if m.cancel != nil {
+		m.cancel()
+	}
+
+	m.waitGroup.Wait()
+	return nil
+}
+
+// Established returns the amount of connections that are currently established
+func (m *ConnectionMerkhet) Established() int {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	return m.Connections - len(m.down)
+}
+
+// Disconnects returns all unexpected disconnects recorded so far
+func (m *ConnectionMerkhet) Disconnects() []merkhet.Disconnect {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	return append([]merkhet.Disconnect{}, m.disconnects...)
+}
+
+// hold keeps the connection with the given index open until the context is canceled
+func (m *ConnectionMerkhet) hold(ctx context.Context, index int) {
+	defer m.waitGroup.Done()
+
+	for {
+		err := m.stream(ctx, index)
+		if ctx.Err() != nil {
+			return
+		}
+		m.dropped(index, err)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(m.ReconnectInterval):
+		}
+	}
+}
+
+// stream opens a connection and reads from it until the connection ends or no data arrived within the idle timeout
+func (m *ConnectionMerkhet) stream(ctx context.Context, index int) error {
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	idle := time.AfterFunc(m.IdleTimeout, cancel)
+	defer idle.Stop()
+
+	request, err := http.NewRequest(http.MethodGet, *m.StreamURL, nil)
+	if err != nil {
+		return err
+	}
+
+	response, err := m.HTTPClient.Do(request.WithContext(streamCtx))
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %d", response.StatusCode)
+	}
+
+	scanner := bufio.NewScanner(response.Body)
+	for scanner.Scan() {
+		idle.Reset(m.IdleTimeout)
+		m.established(index)
+	}
+
+	switch {
+	case ctx.Err() != nil:
+		return ctx.Err()
+	case streamCtx.Err() != nil:
+		return fmt.Errorf("no data received within %s", m.IdleTimeout)
+	case scanner.Err() != nil:
+		return scanner.Err()
+	default:
+		return fmt.Errorf("connection closed by the server")
+	}
+}
+
+// established marks the connection as established and records the reconnect time if the connection dropped before
+func (m *ConnectionMerkhet) established(index int) {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	if _, down := m.down[index]; !down {
+		return
+	}
+	delete(m.down, index)
+
+	for i := len(m.disconnects) - 1; i >= 0; i-- {
+		if m.disconnects[i].Connection == index {
+			if m.disconnects[i].Reconnected.IsZero() {
+				m.disconnects[i].Reconnected = time.Now()
+				m.reconnected = append(m.reconnected, i)
+				m.Base().Logger().WriteString(logger.Info, bunt.Sprintf("Connection #%d SpringGreen{re-established} after %s",
+					index, m.disconnects[i].ReconnectTime()))
+			}
+			break
+		}
+	}
+}
+
+// dropped records an unexpected disconnect, unless the connection was not established at all
+func (m *ConnectionMerkhet) dropped(index int, reason error) {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	if _, down := m.down[index]; down {
+		return
+	}
+
+	m.down[index] = true
+	m.disconnects = append(m.disconnects, merkhet.Disconnect{Connection: index, Time: time.Now(), Reason: reason.Error()})
+	m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Connection #%d Red{dropped}: %s", index, reason.Error()))
+}
@@ -0,0 +1,168 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var (
+	// MaxCurlLoadRate is the highest rate of requests per second the load mode of the curl merkhet supports
+	MaxCurlLoadRate = 10000.0
+)
+
+// CurlLoad configures the load mode of the curl merkhet. Every execution curls the sample app or every target at the
+// given rate of requests per second for the duration of the window, spread across the given amount of workers.
+// The workers are shared by all executions, so overlapping executions never send more concurrent requests.
+// The requests of every target are recorded as a single aggregated sample per execution
+type CurlLoad struct {
+	Rate        float64
+	Concurrency int
+	Window      time.Duration
+	workers     chan struct{}
+}
+
+// NewCurlLoad creates a new load mode configuration with its workers
+func NewCurlLoad(rate float64, concurrency int, window time.Duration) *CurlLoad {
+	return &CurlLoad{Rate: rate, Concurrency: concurrency, Window: window, workers: make(chan struct{}, concurrency)}
+}
+
+// NewLoadTransport creates a http transport for the load mode that keeps enough idle connections for every worker,
+// so the requests measure the platform rather than the connection handling of watchful. If keep alive is disabled,
+// every request opens a new connection instead
+func NewLoadTransport(maxIdleConnections int, maxIdleConnectionsPerHost int, keepAlive bool) *http.Transport {
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          maxIdleConnections,
+		MaxIdleConnsPerHost:   maxIdleConnectionsPerHost,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+		DisableKeepAlives:     !keepAlive,
+	}
+}
+
+// executeLoad curls the sample app or every target at the rate of the load mode until the window elapsed and returns
+// a sample aggregating the requests of every target. Requests are only sent if a worker is free, a request no worker
+// was free for is counted as skipped, so a slow platform neither delays the following requests nor counts as failure
+// of the platform. Instead of logging every request, a summary of the execution is logged
+func (m *CurlMerkhet) executeLoad() []merkhet.Sample {
+	targets := m.Targets
+	if len(targets) < 1 {
+		targets = []CurlTarget{m.sampleAppTarget()}
+	}
+
+	rounds := int(m.Load.Rate * m.Load.Window.Seconds())
+	if rounds < 1 {
+		rounds = 1
+	}
+
+	interval := time.Duration(float64(time.Second) / m.Load.Rate)
+	if interval < time.Nanosecond {
+		interval = time.Nanosecond
+	}
+
+	start := time.Now()
+	deadline := start.Add(m.Load.Window)
+	workers := m.Load.workers
+	runs := make(map[string][]merkhet.Sample)
+	skipped := make(map[string]int)
+	lock := &sync.Mutex{}
+	waitGroup := &sync.WaitGroup{}
+
+	record := func(target CurlTarget, sample merkhet.Sample) {
+		lock.Lock()
+		runs[target.Name] = append(runs[target.Name], sample)
+		lock.Unlock()
+	}
+
+	ticker := time.NewTicker(interval)
+	for round := 0; round < rounds; round++ {
+		if round > 0 {
+			<-ticker.C
+		}
+
+		if round > 0 && time.Now().After(deadline) {
+			break
+		}
+
+		for _, target := range targets {
+			select {
+			case workers <- struct{}{}:
+				waitGroup.Add(1)
+				go func(target CurlTarget) {
+					defer waitGroup.Done()
+
+					requestStart := time.Now()
+					probe, err := m.request(target)
+					record(target, probe.annotate(merkhet.NewSample(requestStart, time.Since(requestStart), err)))
+					<-workers
+				}(target)
+
+			default:
+				lock.Lock()
+				skipped[target.Name]++
+				lock.Unlock()
+			}
+		}
+	}
+	ticker.Stop()
+	waitGroup.Wait()
+
+	samples := make([]merkhet.Sample, len(targets))
+	for i, target := range targets {
+		name := ""
+		if len(m.Targets) > 0 {
+			name = target.Name
+		}
+		samples[i] = merkhet.NewAggregateSample(name, start, time.Since(start), runs[target.Name])
+		samples[i].Aggregate.Skipped = skipped[target.Name]
+	}
+
+	if skipped := merkhet.CountSkipped(samples); skipped > 0 {
+		m.Base().Logger().WriteString(logger.Info, bunt.Sprintf("Skipped %d requests as all %d workers were busy",
+			skipped, m.Load.Concurrency))
+	}
+
+	if merkhet.CountFailures(samples) < 1 {
+		m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Curled %d requests successfully}", merkhet.CountRuns(samples)))
+	}
+
+	for _, sample := range samples {
+		if sample.Failed() && len(sample.Target) > 0 {
+			m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("%s: Red{Failed to curl: } %s", sample.Target, sample.Error))
+		} else if sample.Failed() {
+			m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to curl: } %s", sample.Error))
+		}
+	}
+	return samples
+}
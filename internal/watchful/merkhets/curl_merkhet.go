@@ -21,43 +21,213 @@
 package merkhets
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
+	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
 	"github.com/homeport/watchful/pkg/logger"
 	"github.com/homeport/watchful/pkg/merkhet"
 )
 
-// CurlMerkhet is an implementation of the Merkhet interface that curls against a domain
+var (
+	// MaxCurlBodySize is the maximum amount of bytes of a response body that is matched against the body regex of a target
+	MaxCurlBodySize int64 = 1024 * 1024
+)
+
+// CurlSettings are the settings of the http-availability merkhet
+type CurlSettings struct {
+	Timeout        time.Duration `yaml:"timeout"`
+	ExpectedStatus int           `yaml:"expected-status"`
+	Targets        []CurlTarget  `yaml:"targets"`
+	Rate           float64       `yaml:"rate"`
+	Concurrency    int           `yaml:"concurrency"`
+	KeepAlive      bool          `yaml:"keep-alive"`
+	VerifyIdentity bool          `yaml:"verify-identity"`
+}
+
+// CurlTarget is a single http endpoint probed by the curl merkhet instead of the sample app
+type CurlTarget struct {
+	Name           string            `yaml:"name"`
+	URL            string            `yaml:"url"`
+	Method         string            `yaml:"method"`
+	Headers        map[string]string `yaml:"headers"`
+	ExpectedStatus int               `yaml:"expected-status"`
+	BodyRegex      string            `yaml:"body-regex"`
+
+	bodyRegex *regexp.Regexp
+	identify  bool
+	appGUID   string
+}
+
+// AppIdentity is the identity of the app instance that served a request, as returned by the sample app
+type AppIdentity struct {
+	ApplicationID string `json:"application_id"`
+	InstanceIndex *int   `json:"instance_index"`
+}
+
+// curlProbe contains the measurements of a single request besides its outcome
+type curlProbe struct {
+	timing   *merkhet.Timing
+	instance *int
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "http-availability",
+		DefaultHeartbeat: time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := CurlSettings{Timeout: 30 * time.Second, ExpectedStatus: http.StatusOK, Concurrency: 10, KeepAlive: true,
+				VerifyIdentity: true}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.Timeout <= 0 {
+				return nil, fmt.Errorf("timeout %s has to be a positive duration", settings.Timeout.String())
+			}
+
+			if !validStatusCode(settings.ExpectedStatus) {
+				return nil, fmt.Errorf("expected-status %d is not a valid http status code", settings.ExpectedStatus)
+			}
+
+			names := make(map[string]bool)
+			for i := range settings.Targets {
+				if err := settings.Targets[i].prepare(settings.ExpectedStatus); err != nil {
+					return nil, fmt.Errorf("target #%d: %s", i, err.Error())
+				}
+
+				if names[settings.Targets[i].Name] {
+					return nil, fmt.Errorf("target name %s is used more than once", settings.Targets[i].Name)
+				}
+				names[settings.Targets[i].Name] = true
+			}
+
+			if settings.Rate < 0 || settings.Rate > MaxCurlLoadRate {
+				return nil, fmt.Errorf("rate %v has to be a positive amount of requests per second of at most %v",
+					settings.Rate, MaxCurlLoadRate)
+			}
+
+			if settings.Concurrency < 1 {
+				return nil, fmt.Errorf("concurrency %d has to be at least 1", settings.Concurrency)
+			}
+
+			curlMerkhet := NewCurlMerkhet(context.Dependencies.Domain, context.Base, &http.Client{}, settings.Timeout,
+				context.Dependencies.AppProvider)
+			curlMerkhet.ExpectedStatus = settings.ExpectedStatus
+			curlMerkhet.Targets = settings.Targets
+			if settings.VerifyIdentity {
+				curlMerkhet.Cli = context.Dependencies.CLI
+			}
+
+			if settings.Rate > 0 {
+				window := context.Heartbeat
+				if window <= 0 {
+					window = time.Second
+				}
+
+				hosts := len(settings.Targets)
+				if hosts < 1 {
+					hosts = 1
+				}
+
+				curlMerkhet.HTTPClient.Transport = NewLoadTransport(settings.Concurrency*hosts, settings.Concurrency, settings.KeepAlive)
+				curlMerkhet.Load = NewCurlLoad(settings.Rate, settings.Concurrency, window)
+			}
+			return curlMerkhet, nil
+		},
+	})
+}
+
+// NewCurlTarget creates a new target that is probed with the given method and expects the given status code
+func NewCurlTarget(name string, targetURL string, method string, expectedStatus int, bodyRegex string) (CurlTarget, error) {
+	target := CurlTarget{Name: name, URL: targetURL, Method: method, ExpectedStatus: expectedStatus, BodyRegex: bodyRegex}
+	return target, target.prepare(http.StatusOK)
+}
+
+// prepare validates the target and fills in the defaults of unset fields
+func (t *CurlTarget) prepare(defaultStatus int) error {
+	parsedURL, err := url.Parse(t.URL)
+	if err != nil {
+		return err
+	}
+
+	if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+		return fmt.Errorf("url %s is not a http or https url", t.URL)
+	}
+
+	if len(t.Name) < 1 {
+		t.Name = t.URL
+	}
+
+	if len(t.Method) < 1 {
+		t.Method = http.MethodGet
+	}
+	t.Method = strings.ToUpper(t.Method)
+
+	if t.ExpectedStatus == 0 {
+		t.ExpectedStatus = defaultStatus
+	}
+
+	if !validStatusCode(t.ExpectedStatus) {
+		return fmt.Errorf("expected-status %d is not a valid http status code", t.ExpectedStatus)
+	}
+
+	if len(t.BodyRegex) > 0 {
+		if t.bodyRegex, err = regexp.Compile(t.BodyRegex); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CurlMerkhet is an implementation of the Merkhet interface that curls against a domain.
+// If targets are configured, the merkhet curls against every target instead of the sample app
 type CurlMerkhet struct {
 	CurlDomain        *string
 	BaseDomain        string
 	BaseReference     merkhet.Base
 	HTTPClient        *http.Client
-	SingleAppProvider AppProvider
+	SingleAppProvider merkhet.AppProvider
+	ExpectedStatus    int
+	Targets           []CurlTarget
+	Load              *CurlLoad
+	Cli               cfw.CloudFoundryCLI
+	AppGUID           string
 }
 
 // NewDefaultCurlMerkhet creates a new curl merkhet instance
-func NewDefaultCurlMerkhet(domain string, baseReference merkhet.Base, appProvider AppProvider) *CurlMerkhet {
+func NewDefaultCurlMerkhet(domain string, baseReference merkhet.Base, appProvider merkhet.AppProvider) *CurlMerkhet {
 	return NewCurlMerkhet(domain, baseReference, &http.Client{}, 30*time.Second, appProvider)
 }
 
 // NewCurlMerkhet creates a new curl merkhet instance
-func NewCurlMerkhet(baseDomain string, baseReference merkhet.Base, httpClient *http.Client, timeout time.Duration, appProvider AppProvider) *CurlMerkhet {
+func NewCurlMerkhet(baseDomain string, baseReference merkhet.Base, httpClient *http.Client, timeout time.Duration, appProvider merkhet.AppProvider) *CurlMerkhet {
 	httpClient.Timeout = timeout
 	return &CurlMerkhet{
 		BaseDomain:        baseDomain,
 		BaseReference:     baseReference,
 		HTTPClient:        httpClient,
 		SingleAppProvider: appProvider,
+		ExpectedStatus:    http.StatusOK,
 	}
 }
 
 // Install installs the merkhet. This does virtually nothing as curl doesn't need setup
 func (m *CurlMerkhet) Install() error {
+	if len(m.Targets) > 0 {
+		return nil
+	}
+
 	parsedURL, e := url.Parse(m.BaseDomain)
 	if e != nil {
 		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not parse url from domain %s", m.BaseDomain))
@@ -69,8 +239,14 @@ func (m *CurlMerkhet) Install() error {
 	return nil
 }
 
-// PostConnect is called after watchful was authorized against the cloud foundry cluster
+// PostConnect is called after watchful was authorized against the cloud foundry cluster.
+// The sample app is only pushed if the merkhet curls against it
 func (m *CurlMerkhet) PostConnect() error {
+	if len(m.Targets) > 0 {
+		m.Base().Logger().WriteString(logger.Info, fmt.Sprintf("Post-Connected curl-merkhet with %d targets", len(m.Targets)))
+		return nil
+	}
+
 	infoLog, errorLog, err := m.SingleAppProvider.Push(m.Base().Logger())
 	if err != nil {
 		m.Base().Logger().WriteString(logger.Error, "Could not post-connect upstream sample-app, printing logs")
@@ -79,35 +255,179 @@ func (m *CurlMerkhet) PostConnect() error {
 		return err
 	}
 
+	if m.Cli != nil {
+		output := &bytes.Buffer{}
+		errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Error))
+		if err := m.Cli.AppGUID(m.SingleAppProvider.AppName()).SubscribeOnOut(output).SubscribeOnErr(errorLog).Sync(); err != nil {
+			m.Base().Logger().WriteString(logger.Error, "Could not read the guid of the sample-app, printing logs")
+			errorLog.Flush()
+			return err
+		}
+
+		m.AppGUID = strings.TrimSpace(output.String())
+		m.Base().Logger().WriteString(logger.Info, bunt.Sprintf("Post-Connected curl-merkhet, verifying responses are served by app Gold{%s}", m.AppGUID))
+		return nil
+	}
+
 	m.Base().Logger().WriteString(logger.Info, "Post-Connected curl-merkhet")
 	return nil
 }
 
-// Execute executes one single test. This will curl against the domain
+// Execute executes one single test. This will curl against the domain or every target
 func (m *CurlMerkhet) Execute() error {
-	var curlDomain string
+	if len(m.Targets) < 1 && m.Load == nil {
+		_, err := m.curl(m.sampleAppTarget(), "")
+		return err
+	}
+
+	for _, sample := range m.ExecuteSamples() {
+		if sample.Failed() && len(sample.Target) > 0 {
+			return fmt.Errorf("%s: %s", sample.Target, sample.Error)
+		} else if sample.Failed() {
+			return fmt.Errorf("%s", sample.Error)
+		}
+	}
+	return nil
+}
+
+// ExecuteSamples executes one single test and returns a sample for every target. The targets are curled concurrently.
+// In load mode, the sample app or every target is curled repeatedly and a sample is returned for every request
+func (m *CurlMerkhet) ExecuteSamples() []merkhet.Sample {
+	if m.Load != nil {
+		return m.executeLoad()
+	}
+
+	if len(m.Targets) < 1 {
+		start := time.Now()
+		probe, err := m.curl(m.sampleAppTarget(), "")
+		return []merkhet.Sample{probe.annotate(merkhet.NewSample(start, time.Since(start), err))}
+	}
+
+	samples := make([]merkhet.Sample, len(m.Targets))
+	waitGroup := &sync.WaitGroup{}
+	for i := range m.Targets {
+		waitGroup.Add(1)
+		go func(i int) {
+			defer waitGroup.Done()
+
+			start := time.Now()
+			probe, err := m.curl(m.Targets[i], m.Targets[i].Name+": ")
+			samples[i] = probe.annotate(merkhet.NewTargetSample(m.Targets[i].Name, start, time.Since(start), err))
+		}(i)
+	}
+	waitGroup.Wait()
+	return samples
+}
+
+// sampleAppTarget returns the target describing the route of the sample app
+func (m *CurlMerkhet) sampleAppTarget() CurlTarget {
+	curlDomain := m.BaseDomain
 	if m.CurlDomain != nil {
 		curlDomain = *m.CurlDomain
-	} else {
-		curlDomain = m.BaseDomain
 	}
+	return CurlTarget{Name: curlDomain, URL: curlDomain, Method: http.MethodGet, ExpectedStatus: m.ExpectedStatus,
+		identify: true, appGUID: m.AppGUID}
+}
 
-	response, err := m.HTTPClient.Get(curlDomain)
+// curl sends a single request to the target and logs its outcome. Log messages are prefixed with the given prefix
+func (m *CurlMerkhet) curl(target CurlTarget, prefix string) (curlProbe, error) {
+	probe, err := m.request(target)
 	if err != nil {
-		m.BaseReference.Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to curl: } %s", err.Error()))
-		return err
+		m.BaseReference.Logger().WriteString(logger.Error, bunt.Sprintf("%sRed{Failed to curl: } %s", prefix, err.Error()))
+		return probe, err
 	}
 
-	if response.StatusCode != http.StatusOK {
-		m.BaseReference.Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to curl: } Response Code: %d", response.StatusCode))
-		return fmt.Errorf("the domain %s returned status code %d", m.BaseDomain, response.StatusCode)
+	m.BaseReference.Logger().WriteString(logger.Debug, bunt.Sprintf("%sSpringGreen{Curled successfully}", prefix))
+	return probe, nil
+}
+
+// request sends a single request to the target and checks the response.
+// The probe contains the timing of the request unless the request could not be created
+func (m *CurlMerkhet) request(target CurlTarget) (probe curlProbe, err error) {
+	request, err := http.NewRequest(target.Method, target.URL, nil)
+	if err != nil {
+		return probe, fmt.Errorf("could not create request: %s", err.Error())
 	}
 
-	m.BaseReference.Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Curled successfully}"))
-	return nil
+	for key, value := range target.Headers {
+		if strings.EqualFold(key, "host") {
+			request.Host = value
+			continue
+		}
+		request.Header.Set(key, value)
+	}
+
+	request, trace := traceTiming(request)
+	defer func() {
+		probe.timing = trace.finish()
+	}()
+
+	response, err := m.HTTPClient.Do(request)
+	if err != nil {
+		return probe, err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != target.ExpectedStatus {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		return probe, fmt.Errorf("the domain %s returned status code %d", target.URL, response.StatusCode)
+	}
+
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, MaxCurlBodySize))
+	if err != nil {
+		return probe, fmt.Errorf("could not read response: %s", err.Error())
+	}
+
+	if target.bodyRegex != nil && !target.bodyRegex.Match(body) {
+		return probe, fmt.Errorf("the response body of %s does not match %s", target.URL, target.BodyRegex)
+	}
+
+	if target.identify {
+		identity, err := ParseAppIdentity(body)
+		if err == nil {
+			probe.instance = identity.InstanceIndex
+		}
+
+		if len(target.appGUID) > 0 {
+			if err != nil {
+				return probe, fmt.Errorf("the response body of %s does not contain the identity of the app: %s", target.URL, err.Error())
+			}
+
+			if identity.ApplicationID != target.appGUID {
+				return probe, fmt.Errorf("the response of %s was served by the app %s instead of %s", target.URL,
+					identity.ApplicationID, target.appGUID)
+			}
+		}
+	}
+	return probe, nil
 }
 
 // Base returns the merkhet base instance of the curl merkhet
 func (m *CurlMerkhet) Base() merkhet.Base {
 	return m.BaseReference
 }
+
+// ParseAppIdentity parses the identity of the app instance from the response body of the sample app
+func ParseAppIdentity(body []byte) (AppIdentity, error) {
+	identity := AppIdentity{}
+	if err := json.Unmarshal(body, &identity); err != nil {
+		return identity, err
+	}
+
+	if len(identity.ApplicationID) < 1 || identity.InstanceIndex == nil {
+		return identity, fmt.Errorf("the application id or the instance index is missing")
+	}
+	return identity, nil
+}
+
+// annotate adds the measurements of the probe to the sample
+func (p curlProbe) annotate(sample merkhet.Sample) merkhet.Sample {
+	sample.Timing = p.timing
+	sample.Instance = p.instance
+	return sample
+}
+
+// validStatusCode checks if the code is within the range of http status codes
+func validStatusCode(code int) bool {
+	return code >= 100 && code <= 599
+}
@@ -0,0 +1,87 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+// timingTrace measures the phases of a single http request
+type timingTrace struct {
+	lock         *sync.Mutex
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	timing       merkhet.Timing
+}
+
+// traceTiming returns a copy of the request that reports its phases to the returned trace. The trace starts now
+func traceTiming(request *http.Request) (*http.Request, *timingTrace) {
+	trace := &timingTrace{lock: &sync.Mutex{}, start: time.Now()}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			trace.record(func() { trace.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			trace.record(func() { trace.timing.DNS = time.Since(trace.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			trace.record(func() { trace.connectStart = time.Now() })
+		},
+		ConnectDone: func(string, string, error) {
+			trace.record(func() { trace.timing.Connect = time.Since(trace.connectStart) })
+		},
+		TLSHandshakeStart: func() {
+			trace.record(func() { trace.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			trace.record(func() { trace.timing.TLS = time.Since(trace.tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			trace.record(func() { trace.timing.FirstByte = time.Since(trace.start) })
+		},
+	})), trace
+}
+
+// finish ends the trace and returns the measured timing
+func (t *timingTrace) finish() *merkhet.Timing {
+	defer t.lock.Unlock()
+
+	t.lock.Lock()
+	timing := t.timing
+	timing.Total = time.Since(t.start)
+	return &timing
+}
+
+// record applies the change to the trace, the hooks of a trace may be called concurrently
+func (t *timingTrace) record(change func()) {
+	defer t.lock.Unlock()
+
+	t.lock.Lock()
+	change()
+}
@@ -24,7 +24,7 @@ import (
 	"bytes"
 	"fmt"
 	"regexp"
-	"strconv"
+	"time"
 
 	"github.com/gonvenience/bunt"
 	"github.com/homeport/watchful/pkg/cfw"
@@ -37,17 +37,45 @@ var (
 	TimestampRegex = regexp.MustCompile(".*Timestamp{([0-9]*)}.*")
 )
 
+// LogRecentSettings are the settings of the cf-recent-log-functionality merkhet
+type LogRecentSettings struct {
+	MaxLossRate float64 `yaml:"max-loss-rate"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "cf-recent-log-functionality",
+		DefaultHeartbeat: 10 * time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := LogRecentSettings{MaxLossRate: DefaultMaxLossRate}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.MaxLossRate < 0 || settings.MaxLossRate > 100 {
+				return nil, fmt.Errorf("max-loss-rate %v has to be a percentage between 0 and 100", settings.MaxLossRate)
+			}
+
+			logRecentMerkhet := NewLogRecentMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base)
+			logRecentMerkhet.MaxLossRate = settings.MaxLossRate
+			return logRecentMerkhet, nil
+		},
+	})
+}
+
 // LogRecentMerkhet is an implementation of the Merkhet interface that tests of the recent log fetch is successful
 type LogRecentMerkhet struct {
 	Cli           cfw.CloudFoundryCLI
-	AppProvider   AppProvider
+	AppProvider   merkhet.AppProvider
 	BaseReference merkhet.Base
-	lastTimeStamp int64
+	MaxLossRate   float64
+	sequence      *LogSequence
 }
 
 // NewLogRecentMerkhet creates a new instance of the merkhet implementation to check log recent
-func NewLogRecentMerkhet(cli cfw.CloudFoundryCLI, appProvider AppProvider, baseReference merkhet.Base) *LogRecentMerkhet {
-	return &LogRecentMerkhet{Cli: cli, AppProvider: appProvider, BaseReference: baseReference}
+func NewLogRecentMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base) *LogRecentMerkhet {
+	return &LogRecentMerkhet{Cli: cli, AppProvider: appProvider, BaseReference: baseReference, MaxLossRate: DefaultMaxLossRate,
+		sequence: NewLogSequence(true)}
 }
 
 // Install installs the merkhet, in this case does nothing
@@ -71,38 +99,37 @@ func (m *LogRecentMerkhet) PostConnect() error {
 
 // Execute tests the recent logs
 func (m *LogRecentMerkhet) Execute() error {
+	_, err := m.fetch()
+	return err
+}
+
+// ExecuteSamples tests the recent logs and returns a sample containing the loss of the lines since the last execution
+func (m *LogRecentMerkhet) ExecuteSamples() []merkhet.Sample {
+	start := time.Now()
+	loss, err := m.fetch()
+	sample := merkhet.NewSample(start, time.Since(start), err)
+	sample.LogLoss = loss
+	return []merkhet.Sample{sample}
+}
+
+// fetch fetches the recent logs and evaluates the numbered lines that were not part of a previous fetch
+func (m *LogRecentMerkhet) fetch() (*merkhet.LogLoss, error) {
 	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
 	infoLog := &bytes.Buffer{}
 
 	if err := m.Cli.RecentLogs(m.AppProvider.AppName()).SubscribeOnOut(infoLog).SubscribeOnErr(errorLog).Sync(); err != nil {
 		m.Base().Logger().WriteString(logger.Error, "Could not fetch recent logs")
 		errorLog.Flush()
-		return err
-	}
-
-	foundTimestamps := TimestampRegex.FindAllStringSubmatch(infoLog.String(), -1)
-	if len(foundTimestamps) < 1 {
-		m.Base().Logger().WriteString(logger.Error, "Could not find timestamp in fetched logs")
-		return fmt.Errorf("log did not contain timestamps")
+		return nil, err
 	}
 
-	timeStamp, e := strconv.ParseInt(foundTimestamps[len(foundTimestamps)-1][1], 0, 64)
-	if e != nil {
-		m.Base().Logger().WriteString(logger.Error, "Could not parse timestamp in fetched logs")
-		return e
-	}
-
-	defer func() {
-		m.lastTimeStamp = timeStamp
-	}()
-
-	if timeStamp <= m.lastTimeStamp {
-		m.Base().Logger().WriteString(logger.Error, "Found timestamp is <= to previous one, no new logs")
-		return fmt.Errorf("found timestamp is <= to previous one, no new logs")
+	loss := m.sequence.Evaluate(infoLog.String())
+	if err := checkLogLoss(m.Base().Logger(), loss, m.MaxLossRate); err != nil {
+		return &loss, err
 	}
 
 	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Fetched recent logs successfully}"))
-	return nil
+	return &loss, nil
 }
 
 // Base returns the base reference of the merkhet
@@ -0,0 +1,158 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"sync"
+
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var (
+	// SequenceRegex defines the regex that retrieves the stream and the sequence number of a numbered log line
+	SequenceRegex = regexp.MustCompile("Sequence{([^:}]+):([0-9]+)}")
+
+	// DefaultMaxLossRate is the percentage of log lines the log merkhets may lose on a single execution
+	DefaultMaxLossRate = 10.0
+)
+
+// LogSequence tracks the sequence numbers of the log lines of every instance of the sample app across evaluations.
+// Every instance numbers its lines in a stream of its own, which starts over if the instance is restarted
+type LogSequence struct {
+	Overlapping bool
+	highest     map[string]int64
+	lock        *sync.Mutex
+}
+
+// NewLogSequence creates a new log sequence. An overlapping log sequence expects every evaluation to repeat the
+// lines of the previous ones, eg: the recent logs, and ignores them instead of counting them as out of order.
+// As the recent logs only contain the newest lines, an overlapping evaluation whose output starts after the line
+// following the previous evaluation is considered truncated. The lines before its first line are counted as
+// truncated instead of lost, so only the gaps within the output are lost lines
+func NewLogSequence(overlapping bool) *LogSequence {
+	return &LogSequence{Overlapping: overlapping, highest: make(map[string]int64), lock: &sync.Mutex{}}
+}
+
+// Evaluate computes the log loss of the numbered lines in the output. The lines of a stream are expected to continue
+// where the previous evaluation stopped, lines of a new stream are expected from the first line found on
+func (s *LogSequence) Evaluate(output string) merkhet.LogLoss {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	loss := merkhet.LogLoss{}
+	received := make(map[string]map[int64]bool)
+	lowest := make(map[string]int64)
+	highest := make(map[string]int64)
+	window := make(map[string]int64)
+
+	for _, match := range SequenceRegex.FindAllStringSubmatch(output, -1) {
+		stream := match[1]
+		sequence, err := strconv.ParseInt(match[2], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		if first, ok := window[stream]; !ok || sequence < first {
+			window[stream] = sequence
+		}
+
+		if previous, known := s.highest[stream]; known && sequence <= previous {
+			if !s.Overlapping {
+				loss.OutOfOrder++
+			}
+			continue
+		}
+
+		if received[stream] == nil {
+			received[stream] = make(map[int64]bool)
+			lowest[stream] = sequence
+			highest[stream] = sequence
+		}
+
+		if received[stream][sequence] {
+			continue
+		}
+		received[stream][sequence] = true
+		loss.Received++
+
+		if sequence < highest[stream] {
+			loss.OutOfOrder++
+		}
+
+		if sequence < lowest[stream] {
+			lowest[stream] = sequence
+		}
+
+		if sequence > highest[stream] {
+			highest[stream] = sequence
+		}
+	}
+
+	for stream, sequences := range received {
+		first := lowest[stream]
+		if previous, known := s.highest[stream]; known {
+			first = previous + 1
+			if s.Overlapping && window[stream] > first {
+				loss.Truncated += int(window[stream] - first)
+				first = window[stream]
+			}
+		}
+
+		expected := int(highest[stream] - first + 1)
+		loss.Expected += expected
+		loss.Lost += expected - len(sequences)
+		s.highest[stream] = highest[stream]
+	}
+	return loss
+}
+
+// Reset forgets the lines of all streams, the next evaluation expects every stream from the first line found on
+func (s *LogSequence) Reset() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.highest = make(map[string]int64)
+}
+
+// checkLogLoss logs the log loss and fails if no new lines were received or more lines than allowed were lost
+func checkLogLoss(l logger.Logger, loss merkhet.LogLoss, maxLossRate float64) error {
+	if loss.Received < 1 {
+		l.WriteString(logger.Error, "Could not find new numbered lines in the logs")
+		return fmt.Errorf("log did not contain new numbered lines")
+	}
+
+	message := fmt.Sprintf("Received %d of %d log lines, %.2f%% lost, %d out of order", loss.Received, loss.Expected,
+		loss.Rate(), loss.OutOfOrder)
+	if loss.Truncated > 0 {
+		message += fmt.Sprintf(", %d not evaluated as they were no longer part of the recent logs", loss.Truncated)
+	}
+	if loss.Rate() > maxLossRate {
+		l.WriteString(logger.Error, message)
+		return fmt.Errorf("lost %.2f%% of the log lines, which exceeds the maximum loss rate of %.2f%%", loss.Rate(), maxLossRate)
+	}
+
+	l.WriteString(logger.Debug, message)
+	return nil
+}
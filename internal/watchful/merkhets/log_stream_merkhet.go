@@ -23,7 +23,7 @@ package merkhets
 import (
 	"bytes"
 	"fmt"
-	"strconv"
+	"sync"
 	"time"
 
 	"github.com/gonvenience/bunt"
@@ -32,17 +32,75 @@ import (
 	"github.com/homeport/watchful/pkg/merkhet"
 )
 
-// LogStreamMerkhet is an implementation of the Merkhet interface that tests of the recent log fetch is successful
+// MaxLogBufferSize is the amount of bytes of streamed logs that are kept until the next execution evaluates them.
+// The oldest lines are discarded once it is exceeded, eg: while the heartbeat is stopped between two tasks
+var MaxLogBufferSize = 4 << 20
+
+// LogStreamSettings are the settings of the cf-log-functionality merkhet
+type LogStreamSettings struct {
+	ReconnectInterval time.Duration `yaml:"reconnect-interval"`
+	MaxLossRate       float64       `yaml:"max-loss-rate"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "cf-log-functionality",
+		DefaultHeartbeat: 30 * time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := LogStreamSettings{ReconnectInterval: time.Second, MaxLossRate: DefaultMaxLossRate}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.ReconnectInterval <= 0 {
+				return nil, fmt.Errorf("reconnect-interval %s has to be a positive duration", settings.ReconnectInterval.String())
+			}
+
+			if settings.MaxLossRate < 0 || settings.MaxLossRate > 100 {
+				return nil, fmt.Errorf("max-loss-rate %v has to be a percentage between 0 and 100", settings.MaxLossRate)
+			}
+
+			logStreamMerkhet := NewLogStreamMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base)
+			logStreamMerkhet.ReconnectInterval = settings.ReconnectInterval
+			logStreamMerkhet.MaxLossRate = settings.MaxLossRate
+			return logStreamMerkhet, nil
+		},
+	})
+}
+
+// LogStreamMerkhet is an implementation of the Merkhet interface that tests if the logs of the sample app are streamed.
+// A single log stream is kept open and reopened whenever it ends, every execution evaluates the lines that arrived
+// since the previous one
 type LogStreamMerkhet struct {
-	Cli           cfw.CloudFoundryCLI
-	AppProvider   AppProvider
-	BaseReference merkhet.Base
-	lastTimeStamp int64
+	Cli               cfw.CloudFoundryCLI
+	AppProvider       merkhet.AppProvider
+	BaseReference     merkhet.Base
+	ReconnectInterval time.Duration
+	MaxLossRate       float64
+
+	buffer     *logBuffer
+	sequence   *LogSequence
+	lock       *sync.Mutex
+	waitGroup  *sync.WaitGroup
+	stop       chan struct{}
+	promise    cfw.CommandPromise
+	reconnects int
+	reported   int
 }
 
-// NewLogStreamMerkhet creates a new instance of the merkhet implementation to check log recent
-func NewLogStreamMerkhet(cli cfw.CloudFoundryCLI, appProvider AppProvider, baseReference merkhet.Base) *LogStreamMerkhet {
-	return &LogStreamMerkhet{Cli: cli, AppProvider: appProvider, BaseReference: baseReference}
+// NewLogStreamMerkhet creates a new instance of the merkhet implementation to check the log stream
+func NewLogStreamMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base) *LogStreamMerkhet {
+	return &LogStreamMerkhet{
+		Cli:               cli,
+		AppProvider:       appProvider,
+		BaseReference:     baseReference,
+		ReconnectInterval: time.Second,
+		MaxLossRate:       DefaultMaxLossRate,
+		buffer:            &logBuffer{lock: &sync.Mutex{}, limit: MaxLogBufferSize},
+		sequence:          NewLogSequence(false),
+		lock:              &sync.Mutex{},
+		waitGroup:         &sync.WaitGroup{},
+	}
 }
 
 // Install installs the merkhet, in this case does nothing
@@ -50,7 +108,7 @@ func (m *LogStreamMerkhet) Install() error {
 	return nil
 }
 
-// PostConnect post connects the merkhet, in this case pushes the sample app if not done
+// PostConnect post connects the merkhet, in this case pushes the sample app if not done and opens the log stream
 func (m *LogStreamMerkhet) PostConnect() error {
 	infoLog, errorLog, err := m.AppProvider.Push(m.Base().Logger())
 	if err != nil {
@@ -60,47 +118,185 @@ func (m *LogStreamMerkhet) PostConnect() error {
 		return err
 	}
 
+	m.Open()
 	m.Base().Logger().WriteString(logger.Info, "Post-Connected log-stream-merkhet")
 	return nil
 }
 
-// Execute tests the recent logs
+// Execute tests the streamed logs
 func (m *LogStreamMerkhet) Execute() error {
-	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
-	infoLog := &bytes.Buffer{}
+	_, _, err := m.evaluate()
+	return err
+}
 
-	promise := m.Cli.StreamLogs(m.AppProvider.AppName()).SubscribeOnOut(infoLog).SubscribeOnErr(errorLog)
-	if err := promise.Timeout(5 * time.Second).Sync(); err != nil && err != cfw.ErrorCommandPromiseTimeout {
-		m.Base().Logger().WriteString(logger.Error, "Could not stream logs")
-		errorLog.Flush()
-		return err
+// ExecuteSamples tests the streamed logs and returns a sample containing the loss of the lines and the interruptions
+// of the log stream since the last execution
+func (m *LogStreamMerkhet) ExecuteSamples() []merkhet.Sample {
+	start := time.Now()
+	loss, reconnects, err := m.evaluate()
+	sample := merkhet.NewSample(start, time.Since(start), err)
+	sample.LogLoss = loss
+	sample.Interruptions = reconnects
+	return []merkhet.Sample{sample}
+}
+
+// evaluate evaluates the numbered lines that arrived since the previous execution. An execution fails if the log
+// stream had to be reopened since the previous one, as the lines logged in between are lost. If the buffer discarded
+// lines, the sequence starts over so the discarded lines are not counted as lost
+func (m *LogStreamMerkhet) evaluate() (*merkhet.LogLoss, int, error) {
+	output, discarded := m.buffer.drain()
+	if discarded > 0 {
+		m.Base().Logger().WriteString(logger.Info, fmt.Sprintf("Discarded %d log lines that exceeded the buffer before they were evaluated", discarded))
+		m.sequence.Reset()
 	}
+	loss := m.sequence.Evaluate(output)
 
-	foundTimestamps := TimestampRegex.FindAllStringSubmatch(infoLog.String(), -1)
-	if len(foundTimestamps) < 1 {
-		m.Base().Logger().WriteString(logger.Error, "Could not find timestamp in streamed logs")
-		return fmt.Errorf("log did not contain timestamps")
+	m.lock.Lock()
+	reconnects := m.reconnects - m.reported
+	m.reported = m.reconnects
+	m.lock.Unlock()
+
+	if reconnects > 0 {
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Log stream was reopened %d times since the previous heartbeat", reconnects))
+		return &loss, reconnects, fmt.Errorf("log stream was interrupted %d times", reconnects)
 	}
 
-	timeStamp, e := strconv.ParseInt(foundTimestamps[len(foundTimestamps)-1][1], 0, 64)
-	if e != nil {
-		m.Base().Logger().WriteString(logger.Error, "Could not parse timestamp in streamed logs")
-		return e
+	if err := checkLogLoss(m.Base().Logger(), loss, m.MaxLossRate); err != nil {
+		return &loss, 0, err
 	}
 
-	defer func() {
-		m.lastTimeStamp = timeStamp
-	}()
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Streamed logs successfully}"))
+	return &loss, 0, nil
+}
+
+// Open opens the log stream, which is reopened whenever it ends until the merkhet is closed
+func (m *LogStreamMerkhet) Open() {
+	m.stop = make(chan struct{})
+	m.waitGroup.Add(1)
+	go m.consume(m.stop)
+}
 
-	if timeStamp <= m.lastTimeStamp {
-		m.Base().Logger().WriteString(logger.Error, "Found timestamp is <= to previous one, no new logs")
-		return fmt.Errorf("found timestamp is <= to previous one, no new logs")
+// Close closes the log stream and waits until it is closed
+func (m *LogStreamMerkhet) Close() error {
+	if m.stop == nil {
+		return nil
 	}
 
-	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Streamed logs successfully}"))
+	m.lock.Lock()
+	close(m.stop)
+	if m.promise != nil {
+		_ = m.promise.Kill()
+	}
+	m.lock.Unlock()
+
+	m.waitGroup.Wait()
+	m.stop = nil
 	return nil
 }
 
+// Reconnects returns how often the log stream was reopened so far
+func (m *LogStreamMerkhet) Reconnects() int {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	return m.reconnects
+}
+
+// consume streams the logs into the buffer until the stop channel is closed
+func (m *LogStreamMerkhet) consume(stop chan struct{}) {
+	defer m.waitGroup.Done()
+
+	for {
+		errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+
+		m.lock.Lock()
+		select {
+		case <-stop:
+			m.lock.Unlock()
+			return
+		default:
+		}
+		m.promise = m.Cli.StreamLogs(m.AppProvider.AppName()).SubscribeOnOut(m.buffer).SubscribeOnErr(errorLog)
+		promise := m.promise
+		m.lock.Unlock()
+
+		err := promise.Sync()
+		_, _ = m.buffer.Write([]byte("\n")) // Completes a line the stream ended in the middle of
+
+		m.lock.Lock()
+		m.promise = nil
+		select {
+		case <-stop:
+			m.lock.Unlock()
+			return
+		default:
+		}
+		m.reconnects++
+		m.lock.Unlock()
+
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Log stream ended unexpectedly (%v), reopening it", err))
+		errorLog.Flush()
+
+		select {
+		case <-stop:
+			return
+		case <-time.After(m.ReconnectInterval):
+		}
+	}
+}
+
+// logBuffer is a thread safe buffer of the streamed log lines. It holds at most limit bytes and discards the oldest
+// lines to make room for new ones
+type logBuffer struct {
+	lock      *sync.Mutex
+	buffer    bytes.Buffer
+	limit     int
+	discarded int
+}
+
+// Write appends the data to the buffer
+func (b *logBuffer) Write(p []byte) (int, error) {
+	defer b.lock.Unlock()
+
+	b.lock.Lock()
+	n, err := b.buffer.Write(p)
+	if b.limit > 0 && b.buffer.Len() > b.limit {
+		b.discard(b.buffer.Len() - b.limit)
+	}
+	return n, err
+}
+
+// discard removes at least the given amount of bytes from the beginning of the buffer. Only complete lines are
+// removed, unless a single incomplete line exceeds the limit on its own
+func (b *logBuffer) discard(excess int) {
+	data := b.buffer.Bytes()
+	end := bytes.IndexByte(data[excess-1:], '\n')
+	if end < 0 {
+		b.discarded += bytes.Count(data, []byte("\n")) + 1
+		b.buffer.Reset()
+		return
+	}
+
+	dropped := b.buffer.Next(excess + end)
+	b.discarded += bytes.Count(dropped, []byte("\n"))
+}
+
+// drain returns and removes all complete lines from the buffer together with the amount of lines discarded since the
+// previous call. An incomplete line is kept until it is completed
+func (b *logBuffer) drain() (string, int) {
+	defer b.lock.Unlock()
+
+	b.lock.Lock()
+	discarded := b.discarded
+	b.discarded = 0
+
+	end := bytes.LastIndexByte(b.buffer.Bytes(), '\n')
+	if end < 0 {
+		return "", discarded
+	}
+	return string(b.buffer.Next(end + 1)), discarded
+}
+
 // Base returns the base reference of the merkhet
 func (m *LogStreamMerkhet) Base() merkhet.Base {
 	return m.BaseReference
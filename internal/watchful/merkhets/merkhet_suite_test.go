@@ -21,14 +21,17 @@
 package merkhets
 
 import (
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"os/exec"
 	"testing"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
 	"github.com/gorilla/mux"
+	"github.com/homeport/watchful/pkg/cfw"
 	"github.com/homeport/watchful/pkg/logger"
 )
 
@@ -58,6 +61,99 @@ func NewMockedServer() *MockedServer {
 	}
 }
 
+type FakeCloudFoundryCLI struct {
+	cfw.CloudFoundryCLI
+	Responses      map[string]string
+	Running        int
+	Desired        int
+	Stuck          bool
+	Restarts       []string
+	TaskID         int
+	TaskState      string
+	Calls          []string
+	Failing        string
+	Logs           string
+	StreamDuration string
+}
+
+func (c *FakeCloudFoundryCLI) Curl(path string) cfw.CommandPromise {
+	return cfw.NewSimpleCommandPromise(exec.Command("echo", c.Responses[path]))
+}
+
+func (c *FakeCloudFoundryCLI) Scale(name string, instances int) cfw.CommandPromise {
+	c.Desired = instances
+	if !c.Stuck {
+		c.Running = instances
+	}
+	return cfw.NewSimpleCommandPromise(exec.Command("true"))
+}
+
+func (c *FakeCloudFoundryCLI) Restart(name string) cfw.CommandPromise {
+	return c.restart("restart")
+}
+
+func (c *FakeCloudFoundryCLI) Restage(name string) cfw.CommandPromise {
+	return c.restart("restage")
+}
+
+func (c *FakeCloudFoundryCLI) restart(operation string) cfw.CommandPromise {
+	c.Restarts = append(c.Restarts, operation)
+	c.Running = 0
+	if !c.Stuck {
+		c.Running = c.Desired
+	}
+	return cfw.NewSimpleCommandPromise(exec.Command("true"))
+}
+
+func (c *FakeCloudFoundryCLI) RunTask(app string, command string, name string) cfw.CommandPromise {
+	c.TaskID++
+	return cfw.NewSimpleCommandPromise(exec.Command("echo", fmt.Sprintf("task name: %s\ntask id: %d", name, c.TaskID)))
+}
+
+func (c *FakeCloudFoundryCLI) Tasks(app string) cfw.CommandPromise {
+	return cfw.NewSimpleCommandPromise(exec.Command("echo", fmt.Sprintf("id name state\n%d task %s", c.TaskID, c.TaskState)))
+}
+
+func (c *FakeCloudFoundryCLI) CreateService(service string, plan string, name string) cfw.CommandPromise {
+	return c.call("create-service")
+}
+
+func (c *FakeCloudFoundryCLI) BindService(app string, service string) cfw.CommandPromise {
+	return c.call("bind-service")
+}
+
+func (c *FakeCloudFoundryCLI) UnbindService(app string, service string) cfw.CommandPromise {
+	return c.call("unbind-service")
+}
+
+func (c *FakeCloudFoundryCLI) DeleteService(name string) cfw.CommandPromise {
+	return c.call("delete-service")
+}
+
+func (c *FakeCloudFoundryCLI) DeleteServiceBroker(name string) cfw.CommandPromise {
+	return c.call("delete-service-broker")
+}
+
+func (c *FakeCloudFoundryCLI) call(verb string) cfw.CommandPromise {
+	c.Calls = append(c.Calls, verb)
+	if verb == c.Failing {
+		return cfw.NewSimpleCommandPromise(exec.Command("false"))
+	}
+	return cfw.NewSimpleCommandPromise(exec.Command("true"))
+}
+
+func (c *FakeCloudFoundryCLI) RecentLogs(name string) cfw.CommandPromise {
+	return cfw.NewSimpleCommandPromise(exec.Command("echo", c.Logs))
+}
+
+func (c *FakeCloudFoundryCLI) StreamLogs(name string) cfw.CommandPromise {
+	return cfw.NewSimpleCommandPromise(exec.Command("sh", "-c", `echo "$0"; exec sleep "$1"`, c.Logs, c.StreamDuration))
+}
+
+func (c *FakeCloudFoundryCLI) App(name string) cfw.CommandPromise {
+	return cfw.NewSimpleCommandPromise(exec.Command("echo", fmt.Sprintf("name: %s\ninstances: %d/%d", name, c.Running, c.Desired)))
+}
+
 type DevNullLogger struct {
 }
 
@@ -21,12 +21,19 @@
 package merkhets
 
 import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
 
+	"github.com/homeport/watchful/pkg/cfw"
 	"github.com/homeport/watchful/pkg/merkhet"
 )
 
@@ -59,6 +66,127 @@ var _ = Describe("the implemented merkhets should function correctly", func() {
 		Expect(curlMerkhet.Execute()).To(BeNil())
 	})
 
+	_ = It("should record the timing of requests", func() {
+		Server.Route("/info", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(10 * time.Millisecond)
+			w.Write([]byte("Hello World"))
+		})
+
+		curlMerkhet := NewDefaultCurlMerkhet(Server.Server.URL+"/info", MerkhetBase, NewMutexSingleAppProvider(nil, "", ""))
+		samples := curlMerkhet.ExecuteSamples()
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Failed()).To(BeFalse())
+		Expect(samples[0].Timing).To(Not(BeNil()))
+		Expect(samples[0].Timing.Connect).To(BeNumerically(">", 0))
+		Expect(samples[0].Timing.FirstByte).To(BeNumerically(">=", 10*time.Millisecond))
+		Expect(samples[0].Timing.Total).To(BeNumerically(">=", samples[0].Timing.FirstByte))
+		Expect(samples[0].Timing.Total).To(BeNumerically("<=", samples[0].Duration))
+	})
+
+	_ = It("should curl at the rate of the load mode reusing connections", func() {
+		lock := &sync.Mutex{}
+		connections := make(map[string]bool)
+		Server.Route("/info", func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			connections[r.RemoteAddr] = true
+			lock.Unlock()
+			w.Write([]byte("Hello World"))
+		})
+
+		definition, _ := merkhet.DefaultRegistry.Lookup("http-availability")
+		created, err := definition.Factory(merkhet.FactoryContext{
+			Base:      MerkhetBase,
+			Heartbeat: 200 * time.Millisecond,
+			Settings:  merkhet.Settings{"rate": 50, "concurrency": 2},
+		})
+		Expect(err).To(BeNil())
+
+		curlMerkhet := created.(*CurlMerkhet)
+		curlMerkhet.BaseDomain = Server.Server.URL + "/info"
+		Expect(curlMerkhet.Load.Rate).To(BeEquivalentTo(50))
+		Expect(curlMerkhet.Load.Concurrency).To(BeEquivalentTo(2))
+		Expect(curlMerkhet.Load.Window).To(BeEquivalentTo(200 * time.Millisecond))
+
+		start := time.Now()
+		samples := curlMerkhet.ExecuteSamples()
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Aggregate).To(Not(BeNil()))
+		Expect(samples[0].Runs()).To(BeEquivalentTo(10))
+		Expect(merkhet.CountFailures(samples)).To(BeEquivalentTo(0))
+		Expect(time.Since(start)).To(BeNumerically(">=", 180*time.Millisecond))
+		Expect(len(connections)).To(BeNumerically("<=", 2))
+
+		Server.Route("/fail", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadGateway)
+		})
+		curlMerkhet.BaseDomain = Server.Server.URL + "/fail"
+		Expect(curlMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should skip requests if no worker of the load mode is free", func() {
+		Server.Route("/slow", func(w http.ResponseWriter, r *http.Request) {
+			time.Sleep(300 * time.Millisecond)
+		})
+
+		curlMerkhet := NewDefaultCurlMerkhet(Server.Server.URL+"/slow", MerkhetBase, NewMutexSingleAppProvider(nil, "", ""))
+		curlMerkhet.Load = NewCurlLoad(50, 1, 200*time.Millisecond)
+
+		start := time.Now()
+		samples := curlMerkhet.ExecuteSamples()
+		Expect(time.Since(start)).To(BeNumerically("<", time.Second))
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Runs()).To(BeEquivalentTo(1))
+		Expect(samples[0].Failed()).To(BeFalse())
+		Expect(samples[0].Aggregate.Skipped).To(BeNumerically(">=", 8))
+		Expect(samples[0].Aggregate.Latencies[0]).To(BeZero())
+		Expect(merkhet.CountSkipped(samples)).To(BeEquivalentTo(samples[0].Aggregate.Skipped))
+
+		definition, _ := merkhet.DefaultRegistry.Lookup("http-availability")
+		_, err := definition.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"rate": 2e9}})
+		Expect(err).To(Not(BeNil()))
+	})
+
+	_ = It("should share the workers of the load mode between overlapping executions", func() {
+		lock := &sync.Mutex{}
+		active, highest := 0, 0
+		Server.Route("/slow", func(w http.ResponseWriter, r *http.Request) {
+			lock.Lock()
+			active++
+			if active > highest {
+				highest = active
+			}
+			lock.Unlock()
+
+			time.Sleep(300 * time.Millisecond)
+
+			lock.Lock()
+			active--
+			lock.Unlock()
+		})
+
+		curlMerkhet := NewDefaultCurlMerkhet(Server.Server.URL+"/slow", MerkhetBase, NewMutexSingleAppProvider(nil, "", ""))
+		curlMerkhet.Load = NewCurlLoad(50, 2, 200*time.Millisecond)
+
+		waitGroup := &sync.WaitGroup{}
+		runs := make(chan int, 3)
+		for i := 0; i < 3; i++ {
+			waitGroup.Add(1)
+			go func() {
+				defer waitGroup.Done()
+				runs <- merkhet.CountRuns(curlMerkhet.ExecuteSamples())
+			}()
+		}
+		waitGroup.Wait()
+		close(runs)
+
+		total := 0
+		for count := range runs {
+			total += count
+		}
+		Expect(total).To(BeEquivalentTo(2))
+		Expect(highest).To(BeEquivalentTo(2))
+	})
+
 	_ = It("should fail curl due to timeout", func(done Done) {
 		Server.Route("/info", func(w http.ResponseWriter, r *http.Request) {
 			time.Sleep(1 * time.Second)
@@ -78,4 +206,570 @@ var _ = Describe("the implemented merkhets should function correctly", func() {
 		Expect(curlMerkhet.Execute()).To(Not(BeNil()))
 		close(done)
 	})
+
+	_ = It("should curl every target separately", func() {
+		Server.Route("/info", func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Authorization") != "bearer token" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"name": "watchful"}`))
+		})
+		Server.Route("/health", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		})
+
+		info, err := NewCurlTarget("info", Server.Server.URL+"/info", "", 0, `"name": "watchful"`)
+		Expect(err).To(BeNil())
+		info.Headers = map[string]string{"Authorization": "bearer token"}
+
+		health, err := NewCurlTarget("", Server.Server.URL+"/health", "head", http.StatusOK, "")
+		Expect(err).To(BeNil())
+		Expect(health.Name).To(BeEquivalentTo(Server.Server.URL + "/health"))
+		Expect(health.Method).To(BeEquivalentTo(http.MethodHead))
+
+		curlMerkhet := NewDefaultCurlMerkhet("", MerkhetBase, nil)
+		curlMerkhet.Targets = []CurlTarget{info, health}
+		Expect(curlMerkhet.Install()).To(BeNil())
+
+		samples := curlMerkhet.ExecuteSamples()
+		Expect(samples).To(HaveLen(2))
+		Expect(samples[0].Target).To(BeEquivalentTo("info"))
+		Expect(samples[0].Failed()).To(BeFalse())
+		Expect(samples[1].Target).To(BeEquivalentTo(health.Name))
+		Expect(samples[1].Failed()).To(BeTrue())
+		Expect(curlMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should fail curl if the body does not match", func() {
+		Server.Route("/info", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello World"))
+		})
+
+		target, err := NewCurlTarget("info", Server.Server.URL+"/info", "", 0, "^Goodbye")
+		Expect(err).To(BeNil())
+
+		curlMerkhet := NewDefaultCurlMerkhet("", MerkhetBase, nil)
+		curlMerkhet.Targets = []CurlTarget{target}
+		Expect(curlMerkhet.Execute()).To(Not(BeNil()))
+
+		_, err = NewCurlTarget("info", "ftp://localhost/info", "", 0, "")
+		Expect(err).To(Not(BeNil()))
+	})
+
+	_ = It("should verify the identity of the app serving the sample app", func() {
+		instance := 0
+		Server.Route("/", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(fmt.Sprintf(`{"application_id": "app-guid", "instance_index": %d}`, instance)))
+			instance = (instance + 1) % 2
+		})
+		Server.Route("/other", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("Hello World"))
+		})
+
+		curlMerkhet := NewDefaultCurlMerkhet(Server.Server.URL+"/", MerkhetBase, NewMutexSingleAppProvider(nil, "", ""))
+		curlMerkhet.AppGUID = "app-guid"
+
+		samples := []merkhet.Sample{}
+		for i := 0; i < 3; i++ {
+			samples = append(samples, curlMerkhet.ExecuteSamples()...)
+		}
+		Expect(merkhet.CountFailures(samples)).To(BeEquivalentTo(0))
+		Expect(merkhet.InstanceDistribution(samples)).To(BeEquivalentTo(map[int]int{0: 2, 1: 1}))
+
+		curlMerkhet.AppGUID = "other-guid"
+		Expect(curlMerkhet.Execute()).To(Not(BeNil()))
+
+		curlMerkhet.AppGUID = "app-guid"
+		curlMerkhet.BaseDomain = Server.Server.URL + "/other"
+		Expect(curlMerkhet.Execute()).To(Not(BeNil()))
+
+		identity, err := ParseAppIdentity([]byte(`{"application_id": "app-guid", "instance_index": 3}`))
+		Expect(err).To(BeNil())
+		Expect(identity.ApplicationID).To(BeEquivalentTo("app-guid"))
+		Expect(*identity.InstanceIndex).To(BeEquivalentTo(3))
+
+		_, err = ParseAppIdentity([]byte(`{"application_id": "app-guid"}`))
+		Expect(err).To(Not(BeNil()))
+	})
+
+	_ = It("should request every cloud controller endpoint separately", func() {
+		cli := &FakeCloudFoundryCLI{Responses: map[string]string{
+			"/v3/info":            `{"name": "cf", "build": "1.2.3"}`,
+			"/v3/apps?per_page=1": `{"errors": [{"title": "CF-NotAuthenticated", "detail": "Authentication error"}]}`,
+		}}
+
+		ccMerkhet := NewCloudControllerMerkhet(cli, MerkhetBase, DefaultCloudControllerEndpoints, time.Second)
+		samples := ccMerkhet.ExecuteSamples()
+		Expect(samples).To(HaveLen(2))
+		Expect(samples[0].Target).To(BeEquivalentTo("/v3/info"))
+		Expect(samples[0].Failed()).To(BeFalse())
+		Expect(samples[1].Error).To(ContainSubstring("CF-NotAuthenticated"))
+		Expect(ccMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should parse cloud controller errors", func() {
+		Expect(ParseCloudControllerResponse([]byte(`{"resources": []}`))).To(BeNil())
+		Expect(ParseCloudControllerResponse([]byte(`{"errors": []}`))).To(BeNil())
+		Expect(ParseCloudControllerResponse([]byte(`{"error_code": "CF-InvalidAuthToken", "description": "Invalid Auth Token"}`))).To(Not(BeNil()))
+		Expect(ParseCloudControllerResponse([]byte("502 Bad Gateway"))).To(Not(BeNil()))
+	})
+
+	_ = It("should request tokens from the uaa", func() {
+		Server.Route("/", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"links": {"uaa": {"href": "%s/uaa"}}}`, Server.Server.URL)
+		})
+		Server.Route("/uaa/oauth/token", func(w http.ResponseWriter, r *http.Request) {
+			clientID, clientSecret, _ := r.BasicAuth()
+			Expect(r.ParseForm()).To(BeNil())
+
+			switch {
+			case r.Form.Get("grant_type") == "password" && clientID == "cf" && clientSecret == "" &&
+				r.Form.Get("username") == "admin" && r.Form.Get("password") == "secret":
+			case r.Form.Get("grant_type") == "client_credentials" && clientID == "watchful" && clientSecret == "secret":
+			default:
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte(`{"access_token": "token", "token_type": "bearer"}`))
+		})
+
+		uaaMerkhet := NewUAAMerkhet(MerkhetBase, &http.Client{}, Server.Server.URL, cfw.CloudFoundryCertificate{Username: "admin", Password: "secret"})
+		Expect(uaaMerkhet.PostConnect()).To(BeNil())
+		Expect(uaaMerkhet.TokenEndpoint).To(BeEquivalentTo(Server.Server.URL + "/uaa"))
+		Expect(uaaMerkhet.Execute()).To(BeNil())
+
+		uaaMerkhet.Credentials.Password = "wrong"
+		Expect(uaaMerkhet.Execute()).To(Not(BeNil()))
+
+		uaaMerkhet.GrantType = ClientCredentialsGrant
+		uaaMerkhet.ClientID = "watchful"
+		uaaMerkhet.ClientSecret = "secret"
+		Expect(uaaMerkhet.Execute()).To(BeNil())
+	})
+
+	_ = It("should fall back to the token endpoint of the v2 info", func() {
+		Server.Route("/v2/info", func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprintf(w, `{"token_endpoint": "%s/uaa/"}`, Server.Server.URL)
+		})
+
+		uaaMerkhet := NewUAAMerkhet(MerkhetBase, &http.Client{}, Server.Server.URL+"/", cfw.CloudFoundryCertificate{})
+		Expect(uaaMerkhet.PostConnect()).To(BeNil())
+		Expect(uaaMerkhet.TokenEndpoint).To(BeEquivalentTo(Server.Server.URL + "/uaa"))
+	})
+
+	_ = It("should scale the app up and down", func() {
+		cli := &FakeCloudFoundryCLI{Running: 1, Desired: 1}
+		scaleMerkhet := NewScaleMerkhet(cli, nil, MerkhetBase, 1, 3, time.Second)
+		scaleMerkhet.PollInterval = 10 * time.Millisecond
+
+		Expect(scaleMerkhet.Execute()).To(BeNil())
+		Expect(cli.Desired).To(BeEquivalentTo(3))
+		Expect(scaleMerkhet.Execute()).To(BeNil())
+		Expect(cli.Desired).To(BeEquivalentTo(1))
+
+		cli.Stuck = true
+		Expect(scaleMerkhet.Execute()).To(Not(BeNil()))
+		Expect(cli.Running).To(BeEquivalentTo(1))
+	})
+
+	_ = It("should restart and restage the app until its instances are running", func() {
+		cli := &FakeCloudFoundryCLI{Running: 2, Desired: 2}
+		restartMerkhet := NewRestartMerkhet(cli, nil, MerkhetBase, RestartOperation, time.Second)
+		restartMerkhet.PollInterval = 10 * time.Millisecond
+		Expect(restartMerkhet.Execute()).To(BeNil())
+
+		restartMerkhet.Operation = RestageOperation
+		Expect(restartMerkhet.Execute()).To(BeNil())
+		Expect(cli.Restarts).To(BeEquivalentTo([]string{"restart", "restage"}))
+
+		cli.Stuck = true
+		Expect(restartMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should run tasks until they completed", func() {
+		cli := &FakeCloudFoundryCLI{TaskState: cfw.TaskSucceeded}
+		taskMerkhet := NewTaskMerkhet(cli, NewMutexSingleAppProvider(cli, "sample", ""), MerkhetBase, "sleep 1", time.Second)
+		taskMerkhet.PollInterval = 10 * time.Millisecond
+		Expect(taskMerkhet.Execute()).To(BeNil())
+		Expect(cli.TaskID).To(BeEquivalentTo(1))
+
+		cli.TaskState = cfw.TaskFailed
+		Expect(taskMerkhet.Execute()).To(Not(BeNil()))
+
+		cli.TaskState = cfw.TaskRunning
+		Expect(taskMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should create, bind, unbind and delete service instances", func() {
+		cli := &FakeCloudFoundryCLI{}
+		serviceMerkhet := NewServiceMerkhet(cli, NewMutexSingleAppProvider(cli, "sample", ""), MerkhetBase,
+			ServiceBrokerServiceName, ServiceBrokerPlanName, "", "", time.Second)
+		Expect(serviceMerkhet.Execute()).To(BeNil())
+		Expect(cli.Calls).To(BeEquivalentTo([]string{"create-service", "bind-service", "unbind-service", "delete-service"}))
+
+		cli.Calls, cli.Failing = nil, "bind-service"
+		Expect(serviceMerkhet.Execute()).To(Not(BeNil()))
+		Expect(cli.Calls).To(BeEquivalentTo([]string{"create-service", "bind-service", "unbind-service", "delete-service"}))
+
+		cli.Calls, cli.Failing = nil, "create-service"
+		Expect(serviceMerkhet.Execute()).To(Not(BeNil()))
+		Expect(cli.Calls).To(BeEquivalentTo([]string{"create-service", "unbind-service", "delete-service"}))
+	})
+
+	_ = It("should clean up the remaining service instances and the broker on close", func() {
+		cli := &FakeCloudFoundryCLI{Failing: "delete-service"}
+		serviceMerkhet := NewServiceMerkhet(cli, NewMutexSingleAppProvider(cli, "sample", ""), MerkhetBase,
+			ServiceBrokerServiceName, ServiceBrokerPlanName, "", "", time.Second)
+		serviceMerkhet.registered = true
+		Expect(serviceMerkhet.Execute()).To(Not(BeNil()))
+
+		cli.Calls, cli.Failing = nil, ""
+		Expect(serviceMerkhet.Close()).To(BeNil())
+		Expect(cli.Calls).To(BeEquivalentTo([]string{"unbind-service", "delete-service", "delete-service-broker"}))
+
+		cli.Calls = nil
+		Expect(serviceMerkhet.Close()).To(BeNil())
+		Expect(cli.Calls).To(BeEmpty())
+	})
+
+	_ = It("should run a service broker implementing the open service broker api", func() {
+		serviceMerkhet := NewServiceMerkhet(nil, nil, MerkhetBase, ServiceBrokerServiceName, ServiceBrokerPlanName,
+			"127.0.0.1:0", "http://watchful.foo.com", time.Second)
+		Expect(serviceMerkhet.Install()).To(BeNil())
+		defer serviceMerkhet.Close()
+
+		broker := serviceMerkhet.Broker()
+		request := func(method string, path string, authenticated bool) int {
+			r, err := http.NewRequest(method, "http://"+serviceMerkhet.Address()+path, nil)
+			Expect(err).To(BeNil())
+			if authenticated {
+				r.SetBasicAuth(broker.Username, broker.Password)
+			}
+
+			response, err := http.DefaultClient.Do(r)
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+			return response.StatusCode
+		}
+
+		Expect(request(http.MethodGet, "/v2/catalog", false)).To(BeEquivalentTo(http.StatusUnauthorized))
+		Expect(request(http.MethodGet, "/v2/catalog", true)).To(BeEquivalentTo(http.StatusOK))
+
+		Expect(request(http.MethodPut, "/v2/service_instances/instance", true)).To(BeEquivalentTo(http.StatusCreated))
+		Expect(request(http.MethodPut, "/v2/service_instances/instance/service_bindings/binding", true)).To(BeEquivalentTo(http.StatusCreated))
+		Expect(broker.Instances()).To(BeEquivalentTo(1))
+		Expect(broker.Bindings()).To(BeEquivalentTo(1))
+
+		Expect(request(http.MethodDelete, "/v2/service_instances/instance/service_bindings/binding", true)).To(BeEquivalentTo(http.StatusOK))
+		Expect(request(http.MethodDelete, "/v2/service_instances/instance", true)).To(BeEquivalentTo(http.StatusOK))
+		Expect(request(http.MethodDelete, "/v2/service_instances/instance", true)).To(BeEquivalentTo(http.StatusGone))
+		Expect(broker.Instances()).To(BeEquivalentTo(0))
+		Expect(broker.Bindings()).To(BeEquivalentTo(0))
+	})
+
+	_ = It("should round-trip payloads through a tcp route", func() {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		Expect(err).To(BeNil())
+		defer listener.Close()
+
+		reverse := false
+		go func() {
+			for {
+				connection, err := listener.Accept()
+				if err != nil {
+					return
+				}
+
+				buffer := make([]byte, 8)
+				n, _ := connection.Read(buffer)
+				if reverse {
+					buffer[0], buffer[n-1] = buffer[n-1], buffer[0]
+				}
+				_, _ = connection.Write(buffer[:n])
+				connection.Close()
+			}
+		}()
+
+		port := listener.Addr().(*net.TCPAddr).Port
+		tcpMerkhet := NewTCPMerkhet(nil, nil, MerkhetBase, "127.0.0.1", port, time.Second)
+		Expect(tcpMerkhet.Execute()).To(BeNil())
+
+		reverse = true
+		Expect(tcpMerkhet.Execute()).To(Not(BeNil()))
+
+		listener.Close()
+		Expect(tcpMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should record dropped long-lived connections", func() {
+		Server.Route("/stream", func(w http.ResponseWriter, r *http.Request) {
+			for {
+				if _, err := fmt.Fprintln(w, "Heartbeat{}"); err != nil {
+					return
+				}
+				w.(http.Flusher).Flush()
+
+				select {
+				case <-r.Context().Done():
+					return
+				case <-time.After(10 * time.Millisecond):
+				}
+			}
+		})
+
+		streamURL := Server.Server.URL + "/stream"
+		connectionMerkhet := NewConnectionMerkhet("", MerkhetBase, nil, 2, time.Second)
+		connectionMerkhet.StreamURL = &streamURL
+		connectionMerkhet.ReconnectInterval = 10 * time.Millisecond
+		Expect(connectionMerkhet.Execute()).To(Not(BeNil()))
+
+		connectionMerkhet.Open()
+		defer connectionMerkhet.Close()
+		Eventually(connectionMerkhet.Established).Should(BeEquivalentTo(2))
+		Expect(connectionMerkhet.Execute()).To(BeNil())
+
+		Server.Server.CloseClientConnections()
+		Eventually(func() int { return len(connectionMerkhet.Disconnects()) }).Should(BeEquivalentTo(2))
+		Eventually(connectionMerkhet.Established).Should(BeEquivalentTo(2))
+		samples := connectionMerkhet.ExecuteSamples()
+		Expect(samples).To(HaveLen(1))
+		Expect(samples[0].Error).To(Not(BeNil()))
+		Expect(samples[0].Disconnects).To(HaveLen(2))
+		Expect(connectionMerkhet.Execute()).To(BeNil())
+
+		for _, disconnect := range connectionMerkhet.Disconnects() {
+			Expect(disconnect.ReconnectTime()).To(BeNumerically(">", 0))
+		}
+		for _, disconnect := range samples[0].Disconnects {
+			Expect(disconnect.Time.IsZero()).To(BeFalse())
+		}
+	})
+
+	_ = It("should receive timestamps through a tcp syslog drain", func() {
+		syslogMerkhet := NewSyslogMerkhet(nil, nil, MerkhetBase, "tcp", "127.0.0.1:0", "syslog://localhost:514")
+		Expect(syslogMerkhet.Install()).To(BeNil())
+		defer syslogMerkhet.Close()
+
+		Expect(syslogMerkhet.Execute()).To(Not(BeNil()))
+
+		connection, err := net.Dial("tcp", syslogMerkhet.Address())
+		Expect(err).To(BeNil())
+		defer connection.Close()
+
+		message := "<14>1 2019-01-01T00:00:00Z host app [APP/PROC/WEB/0] - - Timestamp{1546300800}\n"
+		_, err = fmt.Fprintf(connection, "%d %s", len(message), message)
+		Expect(err).To(BeNil())
+
+		Eventually(syslogMerkhet.LatestTimeStamp).Should(BeEquivalentTo(1546300800))
+		Expect(syslogMerkhet.Execute()).To(BeNil())
+		Expect(syslogMerkhet.Execute()).To(Not(BeNil()))
+	})
+
+	_ = It("should receive timestamps through a udp syslog drain", func() {
+		syslogMerkhet := NewSyslogMerkhet(nil, nil, MerkhetBase, "udp", "127.0.0.1:0", "syslog://localhost:514")
+		Expect(syslogMerkhet.Install()).To(BeNil())
+		defer syslogMerkhet.Close()
+
+		connection, err := net.Dial("udp", syslogMerkhet.Address())
+		Expect(err).To(BeNil())
+		defer connection.Close()
+
+		_, err = connection.Write([]byte("<14>1 2019-01-01T00:00:00Z host app [APP/PROC/WEB/0] - - Timestamp{1546300801}"))
+		Expect(err).To(BeNil())
+
+		Eventually(syslogMerkhet.LatestTimeStamp).Should(BeEquivalentTo(1546300801))
+		Expect(syslogMerkhet.Execute()).To(BeNil())
+	})
+
+	_ = It("should read octet counted and new line terminated syslog messages", func() {
+		messages := make([]string, 0)
+		err := ReadSyslogMessages(bufio.NewReader(strings.NewReader("5 first6 secondthird\n")), func(message string) {
+			messages = append(messages, message)
+		})
+
+		Expect(err).To(BeEquivalentTo(io.EOF))
+		Expect(messages).To(BeEquivalentTo([]string{"first", "second", "third\n"}))
+	})
+
+	_ = It("should reject syslog messages with an invalid size", func() {
+		read := func(input string) ([]string, error) {
+			messages := make([]string, 0)
+			err := ReadSyslogMessages(bufio.NewReader(strings.NewReader(input)), func(message string) {
+				messages = append(messages, message)
+			})
+			return messages, err
+		}
+
+		for _, input := range []string{"99999999999 x", "65537 x", "-5 first", "0 first"} {
+			messages, err := read("5 first" + input)
+			Expect(err).To(Not(BeNil()))
+			Expect(err).To(Not(BeEquivalentTo(io.EOF)))
+			Expect(messages).To(BeEquivalentTo([]string{"first"}))
+		}
+
+		messages, err := read(strings.Repeat("x", MaxSyslogMessageSize+1) + "\nsecond\n")
+		Expect(err).To(Not(BeNil()))
+		Expect(messages).To(BeEmpty())
+	})
+
+	_ = It("should register the merkhets implemented by watchful", func() {
+		Expect(merkhet.DefaultRegistry.Names()).To(BeEquivalentTo([]string{"app-pushability", "app-restartability", "app-scalability",
+			"cf-api-availability", "cf-log-functionality", "cf-recent-log-functionality", "cf-service-functionality",
+			"cf-task-functionality", "http-availability", "http-connection-stability", "syslog-functionality", "tcp-availability",
+			"uaa-token-issuance"}))
+	})
+
+	_ = It("should compute the loss of numbered log lines", func() {
+		sequence := NewLogSequence(false)
+		loss := sequence.Evaluate(strings.Join([]string{
+			"[APP/PROC/WEB/0] OUT Timestamp{1546300800} Sequence{a:1}",
+			"[APP/PROC/WEB/0] OUT Timestamp{1546300802} Sequence{a:3}",
+			"[APP/PROC/WEB/0] OUT Timestamp{1546300801} Sequence{a:2}",
+			"[APP/PROC/WEB/0] OUT Timestamp{1546300805} Sequence{a:6}",
+			"[APP/PROC/WEB/1] OUT Timestamp{1546300800} Sequence{b:7}",
+			"[APP/PROC/WEB/1] OUT Timestamp{1546300800} Sequence{b:7}",
+		}, "\n"))
+		Expect(loss).To(BeEquivalentTo(merkhet.LogLoss{Expected: 7, Received: 5, Lost: 2, OutOfOrder: 1}))
+
+		loss = sequence.Evaluate("Sequence{a:4} Sequence{a:10} Sequence{b:8}")
+		Expect(loss).To(BeEquivalentTo(merkhet.LogLoss{Expected: 5, Received: 2, Lost: 3, OutOfOrder: 1}))
+		Expect(loss.Rate()).To(BeNumerically("==", 60))
+
+		overlapping := NewLogSequence(true)
+		Expect(overlapping.Evaluate("Sequence{a:1} Sequence{a:2}").Lost).To(BeEquivalentTo(0))
+		Expect(overlapping.Evaluate("Sequence{a:1} Sequence{a:2} Sequence{a:4}")).To(BeEquivalentTo(
+			merkhet.LogLoss{Expected: 2, Received: 1, Lost: 1}))
+		Expect(overlapping.Evaluate("Sequence{a:9} Sequence{a:11} Sequence{a:12}")).To(BeEquivalentTo(
+			merkhet.LogLoss{Expected: 4, Received: 3, Lost: 1, Truncated: 4}))
+		Expect(overlapping.Evaluate("Sequence{a:12} Sequence{a:13}")).To(BeEquivalentTo(
+			merkhet.LogLoss{Expected: 1, Received: 1}))
+	})
+
+	_ = It("should fail the recent logs if too many lines were lost", func() {
+		cli := &FakeCloudFoundryCLI{Logs: "Sequence{a:1}\nSequence{a:2}\nSequence{a:3}"}
+		logRecentMerkhet := NewLogRecentMerkhet(cli, NewMutexSingleAppProvider(nil, "", ""), MerkhetBase)
+		logRecentMerkhet.MaxLossRate = 25
+
+		samples := logRecentMerkhet.ExecuteSamples()
+		Expect(samples[0].Failed()).To(BeFalse())
+		Expect(samples[0].LogLoss).To(BeEquivalentTo(&merkhet.LogLoss{Expected: 3, Received: 3}))
+
+		Expect(logRecentMerkhet.Execute()).To(Not(BeNil()))
+
+		cli.Logs = "Sequence{a:2}\nSequence{a:3}\nSequence{a:4}\nSequence{a:6}\nSequence{a:7}\nSequence{a:8}"
+		Expect(logRecentMerkhet.Execute()).To(BeNil())
+
+		cli.Logs = "Sequence{a:8}\nSequence{a:11}"
+		samples = logRecentMerkhet.ExecuteSamples()
+		Expect(samples[0].Failed()).To(BeTrue())
+		Expect(samples[0].LogLoss.Rate()).To(BeNumerically("~", 66.67, 0.01))
+	})
+
+	_ = It("should evaluate the lines of the log stream since the previous execution", func() {
+		cli := &FakeCloudFoundryCLI{Logs: "Sequence{a:1}\nSequence{a:2}\nSequence{a:3}", StreamDuration: "10"}
+		logStreamMerkhet := NewLogStreamMerkhet(cli, NewMutexSingleAppProvider(nil, "", ""), MerkhetBase)
+		logStreamMerkhet.Open()
+
+		Eventually(logStreamMerkhet.ExecuteSamples).Should(ContainElement(WithTransform(func(sample merkhet.Sample) bool {
+			return !sample.Failed() && sample.LogLoss.Received == 3
+		}, BeTrue())))
+		Expect(logStreamMerkhet.Execute()).To(Not(BeNil()))
+
+		start := time.Now()
+		Expect(logStreamMerkhet.Close()).To(BeNil())
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(logStreamMerkhet.Reconnects()).To(BeEquivalentTo(0))
+	})
+
+	_ = It("should reopen the log stream and record the interruption", func() {
+		cli := &FakeCloudFoundryCLI{Logs: "Sequence{a:1}", StreamDuration: "0"}
+		logStreamMerkhet := NewLogStreamMerkhet(cli, NewMutexSingleAppProvider(nil, "", ""), MerkhetBase)
+		logStreamMerkhet.ReconnectInterval = 10 * time.Millisecond
+		logStreamMerkhet.Open()
+		defer logStreamMerkhet.Close()
+
+		Eventually(logStreamMerkhet.Reconnects).Should(BeNumerically(">", 0))
+		samples := logStreamMerkhet.ExecuteSamples()
+		Expect(samples[0].Failed()).To(BeTrue())
+		Expect(samples[0].Error).To(ContainSubstring("interrupted"))
+		Expect(samples[0].Interruptions).To(BeNumerically(">", 0))
+	})
+
+	_ = It("should discard the oldest log lines once the buffer is full", func() {
+		buffer := &logBuffer{lock: &sync.Mutex{}, limit: 32}
+		for i := 1; i <= 4; i++ {
+			_, _ = fmt.Fprintf(buffer, "Sequence{a:%d}\n", i)
+		}
+
+		output, discarded := buffer.drain()
+		Expect(discarded).To(BeEquivalentTo(2))
+		Expect(output).To(BeEquivalentTo("Sequence{a:3}\nSequence{a:4}\n"))
+
+		_, _ = buffer.Write([]byte(strings.Repeat("x", 40)))
+		output, discarded = buffer.drain()
+		Expect(discarded).To(BeEquivalentTo(1))
+		Expect(output).To(BeEmpty())
+
+		sequence := NewLogSequence(false)
+		sequence.Evaluate("Sequence{a:1}\n")
+		sequence.Reset()
+		Expect(sequence.Evaluate("Sequence{a:5}\n").Lost).To(BeZero())
+	})
+
+	_ = It("should create merkhets from their settings", func() {
+		definition, _ := merkhet.DefaultRegistry.Lookup("http-availability")
+		created, err := definition.Factory(merkhet.FactoryContext{
+			Base:     MerkhetBase,
+			Settings: merkhet.Settings{"timeout": "10s", "expected-status": 204},
+		})
+		Expect(err).To(BeNil())
+		Expect(created.(*CurlMerkhet).HTTPClient.Timeout).To(BeEquivalentTo(10 * time.Second))
+		Expect(created.(*CurlMerkhet).ExpectedStatus).To(BeEquivalentTo(204))
+
+		definition, _ = merkhet.DefaultRegistry.Lookup("cf-log-functionality")
+		created, err = definition.Factory(merkhet.FactoryContext{Base: MerkhetBase})
+		Expect(err).To(BeNil())
+		Expect(created.(*LogStreamMerkhet).ReconnectInterval).To(BeEquivalentTo(time.Second))
+		Expect(created.(*LogStreamMerkhet).MaxLossRate).To(BeEquivalentTo(DefaultMaxLossRate))
+
+		definition, _ = merkhet.DefaultRegistry.Lookup("cf-recent-log-functionality")
+		created, err = definition.Factory(merkhet.FactoryContext{Base: MerkhetBase, Settings: merkhet.Settings{"max-loss-rate": 2.5}})
+		Expect(err).To(BeNil())
+		Expect(created.(*LogRecentMerkhet).MaxLossRate).To(BeEquivalentTo(2.5))
+
+		_, err = definition.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"max-loss-rate": 120}})
+		Expect(err).NotTo(BeNil())
+	})
+
+	_ = It("should reject invalid settings", func() {
+		curl, _ := merkhet.DefaultRegistry.Lookup("http-availability")
+		_, err := curl.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"timeout": "-1s"}})
+		Expect(err).NotTo(BeNil())
+
+		_, err = curl.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"expected-statsu": 200}})
+		Expect(err).NotTo(BeNil())
+
+		_, err = curl.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"rate": 50, "concurrency": 0}})
+		Expect(err).NotTo(BeNil())
+
+		syslog, _ := merkhet.DefaultRegistry.Lookup("syslog-functionality")
+		_, err = syslog.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"listen-address": ":5514"}})
+		Expect(err).NotTo(BeNil())
+
+		service, _ := merkhet.DefaultRegistry.Lookup("cf-service-functionality")
+		_, err = service.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"service": "p-mysql"}})
+		Expect(err).NotTo(BeNil())
+
+		_, err = service.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"broker-url": "http://watchful.foo.com", "plan": "large"}})
+		Expect(err).NotTo(BeNil())
+
+		_, err = service.Factory(merkhet.FactoryContext{Heartbeat: time.Minute, Settings: merkhet.Settings{"service": "p-mysql",
+			"plan": "small", "timeout": "1m"}})
+		Expect(err).NotTo(BeNil())
+
+		tcp, _ := merkhet.DefaultRegistry.Lookup("tcp-availability")
+		_, err = tcp.Factory(merkhet.FactoryContext{Settings: merkhet.Settings{"domain": "tcp.foo.com"}})
+		Expect(err).NotTo(BeNil())
+	})
 })
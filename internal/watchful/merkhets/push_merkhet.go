@@ -21,21 +21,33 @@
 package merkhets
 
 import (
+	"time"
+
 	"github.com/gonvenience/bunt"
 	"github.com/homeport/watchful/pkg/cfw"
 	"github.com/homeport/watchful/pkg/logger"
 	"github.com/homeport/watchful/pkg/merkhet"
 )
 
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "app-pushability",
+		DefaultHeartbeat: time.Minute,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			return NewPushMerkhet(context.Base, context.Dependencies.AppProvider, context.Dependencies.CLI), nil
+		},
+	})
+}
+
 // PushMerkhet is a merkhet implementation that pushes a cf app to the instance
 type PushMerkhet struct {
 	BaseReference   merkhet.Base
 	CloudFoundryCLI cfw.CloudFoundryCLI
-	AppProvider     AppProvider
+	AppProvider     merkhet.AppProvider
 }
 
 // NewPushMerkhet creates a new push merkhet
-func NewPushMerkhet(baseReference merkhet.Base, appProvider AppProvider, cloudFoundryCLI cfw.CloudFoundryCLI) *PushMerkhet {
+func NewPushMerkhet(baseReference merkhet.Base, appProvider merkhet.AppProvider, cloudFoundryCLI cfw.CloudFoundryCLI) *PushMerkhet {
 	return &PushMerkhet{BaseReference: baseReference, AppProvider: appProvider, CloudFoundryCLI: cloudFoundryCLI}
 }
 
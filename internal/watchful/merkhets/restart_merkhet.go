@@ -0,0 +1,168 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+const (
+	// RestartOperation restarts the app with its current droplet
+	RestartOperation = "restart"
+
+	// RestageOperation restages the app, which stages a new droplet before restarting it
+	RestageOperation = "restage"
+)
+
+// RestartSettings are the settings of the app-restartability merkhet
+type RestartSettings struct {
+	Operation    string        `yaml:"operation"`
+	Timeout      time.Duration `yaml:"timeout"`
+	PollInterval time.Duration `yaml:"poll-interval"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "app-restartability",
+		DefaultHeartbeat: 5 * time.Minute,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := RestartSettings{Operation: RestartOperation, Timeout: 3 * time.Minute, PollInterval: 2 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.Operation != RestartOperation && settings.Operation != RestageOperation {
+				return nil, fmt.Errorf("operation has to be either %s or %s, not %s",
+					RestartOperation, RestageOperation, settings.Operation)
+			}
+
+			if settings.Timeout <= 0 || settings.PollInterval <= 0 {
+				return nil, fmt.Errorf("timeout and poll-interval have to be positive durations")
+			}
+
+			restartMerkhet := NewRestartMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base,
+				settings.Operation, settings.Timeout)
+			restartMerkhet.PollInterval = settings.PollInterval
+			return restartMerkhet, nil
+		},
+	})
+}
+
+// RestartMerkhet is an implementation of the Merkhet interface that restarts or restages an app and measures the
+// time until all of its instances are running again. Like the scale merkhet, it pushes its own app named after
+// the merkhet, so the sample app keeps serving the other merkhets
+type RestartMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	AppProvider   merkhet.AppProvider
+	BaseReference merkhet.Base
+	Operation     string
+	Timeout       time.Duration
+	PollInterval  time.Duration
+}
+
+// NewRestartMerkhet creates a new instance of the merkhet implementation to check restarts of apps
+func NewRestartMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base,
+	operation string, timeout time.Duration) *RestartMerkhet {
+	return &RestartMerkhet{
+		Cli:           cli,
+		AppProvider:   appProvider,
+		BaseReference: baseReference,
+		Operation:     operation,
+		Timeout:       timeout,
+		PollInterval:  2 * time.Second,
+	}
+}
+
+// Install installs the merkhet, in this case does nothing
+func (m *RestartMerkhet) Install() error {
+	return nil
+}
+
+// PostConnect pushes the app of the merkhet
+func (m *RestartMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.ForcePush(m.Base().Logger(), m.AppName())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not push the app of the restart-merkhet, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected restart-merkhet")
+	return nil
+}
+
+// Execute restarts or restages the app and waits until all of its instances are running within the timeout
+func (m *RestartMerkhet) Execute() error {
+	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+	start := time.Now()
+	deadline := start.Add(m.Timeout)
+
+	if err := m.command().SubscribeOnErr(errorLog).Timeout(m.Timeout).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to %s} %s after %s",
+			m.Operation, m.AppName(), time.Since(start)))
+		errorLog.Flush()
+		return err
+	}
+
+	current, err := awaitAppInstances(m.Cli, m.Base().Logger(), m.AppName(), deadline, m.PollInterval, func(current cfw.AppInstances) bool {
+		return current.Desired > 0 && current.Settled(current.Desired)
+	})
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to read instances: } %s", err.Error()))
+		return err
+	}
+
+	if current.Desired == 0 || !current.Settled(current.Desired) {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Instances were not running} within %s, %d/%d running",
+			m.Timeout, current.Running, current.Desired))
+		return fmt.Errorf("%d/%d instances of %s were running %s after the %s", current.Running, current.Desired,
+			m.AppName(), m.Timeout, m.Operation)
+	}
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{All instances running} %s after the %s",
+		time.Since(start), m.Operation))
+	return nil
+}
+
+// AppName returns the name of the app the merkhet restarts
+func (m *RestartMerkhet) AppName() string {
+	return m.Base().Configuration().Name()
+}
+
+// Base returns the base reference of the merkhet
+func (m *RestartMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// command creates the command promise of the configured operation
+func (m *RestartMerkhet) command() cfw.CommandPromise {
+	if m.Operation == RestageOperation {
+		return m.Cli.Restage(m.AppName())
+	}
+	return m.Cli.Restart(m.AppName())
+}
@@ -27,17 +27,6 @@ import (
 	"github.com/homeport/watchful/pkg/logger"
 )
 
-// AppProvider defines an object that provides one single pushed app in the cf
-//
-// Push pushes the app if not pushed yet
-//
-// AppName returns the app name
-type AppProvider interface {
-	Push(l logger.Logger) (infoLog logger.CachedLogger, errorLog logger.CachedLogger, err error)
-	ForcePush(l logger.Logger, appName string) (infoLog logger.CachedLogger, errorLog logger.CachedLogger, err error)
-	AppName() string
-}
-
 // MutexAppProvider is a mutex.lock based implementation of the app provider
 type MutexAppProvider struct {
 	AppNameValue string
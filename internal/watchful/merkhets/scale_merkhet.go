@@ -0,0 +1,167 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+// ScaleSettings are the settings of the app-scalability merkhet
+type ScaleSettings struct {
+	MinInstances int           `yaml:"min-instances"`
+	MaxInstances int           `yaml:"max-instances"`
+	Deadline     time.Duration `yaml:"deadline"`
+	PollInterval time.Duration `yaml:"poll-interval"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "app-scalability",
+		DefaultHeartbeat: 2 * time.Minute,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := ScaleSettings{MinInstances: 1, MaxInstances: 2, Deadline: 2 * time.Minute, PollInterval: 2 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.MinInstances < 1 || settings.MaxInstances <= settings.MinInstances {
+				return nil, fmt.Errorf("min-instances %d has to be at least 1 and less than max-instances %d",
+					settings.MinInstances, settings.MaxInstances)
+			}
+
+			if settings.Deadline <= 0 || settings.PollInterval <= 0 {
+				return nil, fmt.Errorf("deadline and poll-interval have to be positive durations")
+			}
+
+			scaleMerkhet := NewScaleMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base,
+				settings.MinInstances, settings.MaxInstances, settings.Deadline)
+			scaleMerkhet.PollInterval = settings.PollInterval
+			return scaleMerkhet, nil
+		},
+	})
+}
+
+// ScaleMerkhet is an implementation of the Merkhet interface that scales an app up and down and checks that
+// the new instances are running within a deadline. The merkhet pushes its own app, named after the merkhet,
+// to not disturb the merkhets relying on the sample app
+type ScaleMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	AppProvider   merkhet.AppProvider
+	BaseReference merkhet.Base
+	MinInstances  int
+	MaxInstances  int
+	Deadline      time.Duration
+	PollInterval  time.Duration
+	instances     int
+}
+
+// NewScaleMerkhet creates a new instance of the merkhet implementation to check the scaling of apps
+func NewScaleMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base,
+	minInstances int, maxInstances int, deadline time.Duration) *ScaleMerkhet {
+	return &ScaleMerkhet{
+		Cli:           cli,
+		AppProvider:   appProvider,
+		BaseReference: baseReference,
+		MinInstances:  minInstances,
+		MaxInstances:  maxInstances,
+		Deadline:      deadline,
+		PollInterval:  2 * time.Second,
+		instances:     1,
+	}
+}
+
+// Install installs the merkhet, in this case does nothing
+func (m *ScaleMerkhet) Install() error {
+	return nil
+}
+
+// PostConnect pushes the app of the merkhet and scales it to the minimum amount of instances
+func (m *ScaleMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.ForcePush(m.Base().Logger(), m.AppName())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not push the app of the scale-merkhet, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	if err := m.scale(m.MinInstances); err != nil {
+		return err
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected scale-merkhet")
+	return nil
+}
+
+// Execute scales the app to the other end of the instance range and waits until the instances settled
+func (m *ScaleMerkhet) Execute() error {
+	if m.instances == m.MaxInstances {
+		return m.scale(m.MinInstances)
+	}
+	return m.scale(m.MaxInstances)
+}
+
+// AppName returns the name of the app the merkhet scales
+func (m *ScaleMerkhet) AppName() string {
+	return m.Base().Configuration().Name()
+}
+
+// Base returns the base reference of the merkhet
+func (m *ScaleMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// scale scales the app to the given amount of instances and waits until exactly that amount is running
+func (m *ScaleMerkhet) scale(instances int) error {
+	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+	deadline := time.Now().Add(m.Deadline)
+
+	if err := m.Cli.Scale(m.AppName(), instances).SubscribeOnErr(errorLog).Timeout(m.Deadline).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to scale} to %d instances", instances))
+		errorLog.Flush()
+		return err
+	}
+	m.instances = instances
+
+	current, err := awaitAppInstances(m.Cli, m.Base().Logger(), m.AppName(), deadline, m.PollInterval, func(current cfw.AppInstances) bool {
+		return current.Settled(instances)
+	})
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to read instances: } %s", err.Error()))
+		return err
+	}
+
+	if !current.Settled(instances) {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Instances did not settle} within %s, %d/%d running",
+			m.Deadline, current.Running, current.Desired))
+		return fmt.Errorf("%d/%d instances of %s were running after %s, expected %d", current.Running, current.Desired,
+			m.AppName(), m.Deadline, instances)
+	}
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Scaled successfully} to %d instances", instances))
+	return nil
+}
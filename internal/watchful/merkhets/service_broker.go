@@ -0,0 +1,183 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+
+	"github.com/gorilla/mux"
+)
+
+const (
+	// ServiceBrokerServiceName is the name of the only service in the catalog of the service broker
+	ServiceBrokerServiceName = "watchful-service"
+
+	// ServiceBrokerPlanName is the name of the only plan of the service in the catalog of the service broker
+	ServiceBrokerPlanName = "default"
+)
+
+// ServiceBroker is a minimal in-memory implementation of the open service broker api. It offers a single
+// bindable service, remembers the provisioned instances and bindings and hands out empty credentials.
+// The ids of the service and plan are random, as the cloud controller requires them to be unique across brokers
+type ServiceBroker struct {
+	Username  string
+	Password  string
+	ServiceID string
+	PlanID    string
+
+	router    *mux.Router
+	lock      *sync.Mutex
+	instances map[string]bool
+	bindings  map[string]bool
+}
+
+// NewServiceBroker creates a new service broker that requires the given basic auth credentials
+func NewServiceBroker(username string, password string) *ServiceBroker {
+	broker := &ServiceBroker{
+		Username:  username,
+		Password:  password,
+		ServiceID: RandomID(),
+		PlanID:    RandomID(),
+		router:    mux.NewRouter(),
+		lock:      &sync.Mutex{},
+		instances: make(map[string]bool),
+		bindings:  make(map[string]bool),
+	}
+
+	broker.router.HandleFunc("/v2/catalog", broker.catalog).Methods(http.MethodGet)
+	broker.router.HandleFunc("/v2/service_instances/{instance}", broker.provision).Methods(http.MethodPut)
+	broker.router.HandleFunc("/v2/service_instances/{instance}", broker.deprovision).Methods(http.MethodDelete)
+	broker.router.HandleFunc("/v2/service_instances/{instance}/service_bindings/{binding}", broker.bind).Methods(http.MethodPut)
+	broker.router.HandleFunc("/v2/service_instances/{instance}/service_bindings/{binding}", broker.unbind).Methods(http.MethodDelete)
+	return broker
+}
+
+// ServeHTTP authenticates the request and dispatches it to the endpoints of the service broker api
+func (b *ServiceBroker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if username, password, ok := r.BasicAuth(); !ok || username != b.Username || password != b.Password {
+		writeBrokerResponse(w, http.StatusUnauthorized, map[string]string{"description": "invalid broker credentials"})
+		return
+	}
+
+	b.router.ServeHTTP(w, r)
+}
+
+// Instances returns the amount of currently provisioned service instances
+func (b *ServiceBroker) Instances() int {
+	defer b.lock.Unlock()
+
+	b.lock.Lock()
+	return len(b.instances)
+}
+
+// Bindings returns the amount of current service bindings
+func (b *ServiceBroker) Bindings() int {
+	defer b.lock.Unlock()
+
+	b.lock.Lock()
+	return len(b.bindings)
+}
+
+// catalog responds with the single service and plan offered by the broker
+func (b *ServiceBroker) catalog(w http.ResponseWriter, r *http.Request) {
+	writeBrokerResponse(w, http.StatusOK, map[string]interface{}{
+		"services": []map[string]interface{}{{
+			"id":          b.ServiceID,
+			"name":        ServiceBrokerServiceName,
+			"description": "Service offered by watchful to measure the availability of the marketplace",
+			"bindable":    true,
+			"plans": []map[string]interface{}{{
+				"id":          b.PlanID,
+				"name":        ServiceBrokerPlanName,
+				"description": "Service instance without any resources",
+				"free":        true,
+			}},
+		}},
+	})
+}
+
+// provision provisions a service instance, which is only remembered by the broker
+func (b *ServiceBroker) provision(w http.ResponseWriter, r *http.Request) {
+	b.put(w, b.instances, mux.Vars(r)["instance"], map[string]interface{}{})
+}
+
+// deprovision deprovisions a service instance
+func (b *ServiceBroker) deprovision(w http.ResponseWriter, r *http.Request) {
+	b.delete(w, b.instances, mux.Vars(r)["instance"])
+}
+
+// bind binds a service instance, the binding contains empty credentials
+func (b *ServiceBroker) bind(w http.ResponseWriter, r *http.Request) {
+	b.put(w, b.bindings, mux.Vars(r)["binding"], map[string]interface{}{"credentials": map[string]string{}})
+}
+
+// unbind removes a binding of a service instance
+func (b *ServiceBroker) unbind(w http.ResponseWriter, r *http.Request) {
+	b.delete(w, b.bindings, mux.Vars(r)["binding"])
+}
+
+// put stores the id in the given resources and responds with 201, or 200 if the resource already existed
+func (b *ServiceBroker) put(w http.ResponseWriter, resources map[string]bool, id string, body interface{}) {
+	b.lock.Lock()
+	existed := resources[id]
+	resources[id] = true
+	b.lock.Unlock()
+
+	if existed {
+		writeBrokerResponse(w, http.StatusOK, body)
+		return
+	}
+	writeBrokerResponse(w, http.StatusCreated, body)
+}
+
+// delete removes the id from the given resources and responds with 200, or 410 if the resource did not exist
+func (b *ServiceBroker) delete(w http.ResponseWriter, resources map[string]bool, id string) {
+	b.lock.Lock()
+	existed := resources[id]
+	delete(resources, id)
+	b.lock.Unlock()
+
+	if !existed {
+		writeBrokerResponse(w, http.StatusGone, map[string]interface{}{})
+		return
+	}
+	writeBrokerResponse(w, http.StatusOK, map[string]interface{}{})
+}
+
+// writeBrokerResponse writes the body as json with the given status code
+func writeBrokerResponse(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// RandomID returns a random hex encoded id of 16 bytes
+func RandomID() string {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(id)
+}
@@ -0,0 +1,309 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+// ServiceSettings are the settings of the cf-service-functionality merkhet
+type ServiceSettings struct {
+	Service       string        `yaml:"service"`
+	Plan          string        `yaml:"plan"`
+	ListenAddress string        `yaml:"listen-address"`
+	BrokerURL     string        `yaml:"broker-url"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "cf-service-functionality",
+		DefaultHeartbeat: time.Minute,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := ServiceSettings{Timeout: 45 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if len(settings.BrokerURL) > 0 {
+				if len(settings.Service) < 1 && len(settings.Plan) < 1 {
+					settings.Service, settings.Plan = ServiceBrokerServiceName, ServiceBrokerPlanName
+				}
+
+				if settings.Service != ServiceBrokerServiceName || settings.Plan != ServiceBrokerPlanName {
+					return nil, fmt.Errorf("the service broker of watchful only offers the service %s with the plan %s",
+						ServiceBrokerServiceName, ServiceBrokerPlanName)
+				}
+			}
+
+			if len(settings.Service) < 1 || len(settings.Plan) < 1 {
+				return nil, fmt.Errorf("either a broker-url reachable from the cloud foundry instance or a service and plan of the marketplace is required")
+			}
+
+			if settings.Timeout <= 0 {
+				return nil, fmt.Errorf("timeout has to be a positive duration")
+			}
+
+			if context.Heartbeat > 0 && settings.Timeout >= context.Heartbeat {
+				return nil, fmt.Errorf("timeout %s has to be shorter than the heartbeat %s", settings.Timeout.String(),
+					context.Heartbeat.String())
+			}
+
+			return NewServiceMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base,
+				settings.Service, settings.Plan, settings.ListenAddress, settings.BrokerURL, settings.Timeout), nil
+		},
+	})
+}
+
+// ServiceMerkhet is an implementation of the Merkhet interface that creates, binds, unbinds and deletes a service
+// instance on every execution. The service is either offered by the marketplace or by a service broker run by
+// watchful itself, which is registered as a space scoped broker
+type ServiceMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	AppProvider   merkhet.AppProvider
+	BaseReference merkhet.Base
+	Service       string
+	Plan          string
+	ListenAddress string
+	BrokerURL     string
+	Timeout       time.Duration
+
+	broker     *ServiceBroker
+	listener   net.Listener
+	registered bool
+	lock       *sync.Mutex
+	runs       int
+	running    bool
+	pending    map[string]bool
+}
+
+// NewServiceMerkhet creates a new instance of the merkhet implementation to check the lifecycle of service instances
+func NewServiceMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base, service string,
+	plan string, listenAddress string, brokerURL string, timeout time.Duration) *ServiceMerkhet {
+	return &ServiceMerkhet{
+		Cli:           cli,
+		AppProvider:   appProvider,
+		BaseReference: baseReference,
+		Service:       service,
+		Plan:          plan,
+		ListenAddress: listenAddress,
+		BrokerURL:     brokerURL,
+		Timeout:       timeout,
+		lock:          &sync.Mutex{},
+		pending:       make(map[string]bool),
+	}
+}
+
+// Install starts the service broker if a broker url is configured
+func (m *ServiceMerkhet) Install() error {
+	if len(m.BrokerURL) < 1 {
+		return nil
+	}
+
+	listener, err := net.Listen("tcp", m.ListenAddress)
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not listen for service broker requests on %s", m.ListenAddress))
+		return err
+	}
+
+	m.listener = listener
+	m.broker = NewServiceBroker(m.Base().Configuration().Name(), RandomID())
+	go func() {
+		_ = http.Serve(listener, m.broker)
+	}()
+
+	m.Base().Logger().WriteString(logger.Info, fmt.Sprintf("Listening for service broker requests on %s", m.Address()))
+	return nil
+}
+
+// PostConnect pushes the sample app if not done and registers the service broker if it is run by watchful
+func (m *ServiceMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.Push(m.Base().Logger())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not post-connect upstream sample-app, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	if m.broker != nil {
+		errorLog = logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Error))
+		brokerName := m.Base().Configuration().Name()
+		if err := m.Cli.CreateServiceBroker(brokerName, m.broker.Username, m.broker.Password, m.BrokerURL).
+			SubscribeOnErr(errorLog).Sync(); err != nil {
+			m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not create service broker %s", brokerName))
+			errorLog.Flush()
+			return err
+		}
+		m.registered = true
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected service-merkhet")
+	return nil
+}
+
+// Execute creates a service instance, binds it to the sample app, unbinds it and deletes it again. If a step fails,
+// the service instance is cleaned up before the next execution. An execution fails right away if the lifecycle of the
+// previous execution is still running
+func (m *ServiceMerkhet) Execute() error {
+	m.lock.Lock()
+	if m.running {
+		m.lock.Unlock()
+		m.Base().Logger().WriteString(logger.Error, "Lifecycle of the previous service instance is still running")
+		return fmt.Errorf("the lifecycle of the previous service instance is still running")
+	}
+
+	instance := fmt.Sprintf("%s-%d", m.Base().Configuration().Name(), m.runs)
+	m.runs++
+	m.running = true
+	m.pending[instance] = true
+	m.lock.Unlock()
+
+	defer func() {
+		m.lock.Lock()
+		m.running = false
+		m.lock.Unlock()
+	}()
+
+	deadline := time.Now().Add(m.Timeout)
+	if err := m.step("create service instance "+instance, deadline, func() cfw.CommandPromise {
+		return m.Cli.CreateService(m.Service, m.Plan, instance)
+	}); err != nil {
+		m.cleanup(instance)
+		return err
+	}
+
+	if err := m.step("bind service instance "+instance, deadline, func() cfw.CommandPromise {
+		return m.Cli.BindService(m.AppProvider.AppName(), instance)
+	}); err != nil {
+		m.cleanup(instance)
+		return err
+	}
+
+	if err := m.step("unbind service instance "+instance, deadline, func() cfw.CommandPromise {
+		return m.Cli.UnbindService(m.AppProvider.AppName(), instance)
+	}); err != nil {
+		m.cleanup(instance)
+		return err
+	}
+
+	if err := m.step("delete service instance "+instance, deadline, func() cfw.CommandPromise {
+		return m.Cli.DeleteService(instance)
+	}); err != nil {
+		m.cleanup(instance)
+		return err
+	}
+	m.deleted(instance)
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Completed lifecycle} of service instance %s", instance))
+	return nil
+}
+
+// Base returns the base reference of the merkhet
+func (m *ServiceMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// Close deletes the service instances that could not be cleaned up so far and deregisters and stops the service
+// broker. The service instances are deleted first, as the broker has to be reachable to deprovision them
+func (m *ServiceMerkhet) Close() error {
+	m.lock.Lock()
+	instances := make([]string, 0, len(m.pending))
+	for instance := range m.pending {
+		instances = append(instances, instance)
+	}
+	m.lock.Unlock()
+
+	for _, instance := range instances {
+		m.cleanup(instance)
+	}
+
+	if m.registered {
+		brokerName := m.Base().Configuration().Name()
+		errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Error))
+		if err := m.Cli.DeleteServiceBroker(brokerName).SubscribeOnErr(errorLog).Timeout(m.Timeout).Sync(); err != nil {
+			m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not delete service broker %s", brokerName))
+			errorLog.Flush()
+		} else {
+			m.registered = false
+		}
+	}
+
+	if m.listener == nil {
+		return nil
+	}
+	return m.listener.Close()
+}
+
+// Address returns the address the service broker is bound to, or the configured address if it was not started yet
+func (m *ServiceMerkhet) Address() string {
+	if m.listener == nil {
+		return m.ListenAddress
+	}
+	return m.listener.Addr().String()
+}
+
+// Broker returns the service broker run by the merkhet, or nil if the service of the marketplace is used
+func (m *ServiceMerkhet) Broker() *ServiceBroker {
+	return m.broker
+}
+
+// step runs the command created by the given function with the time remaining until the deadline
+func (m *ServiceMerkhet) step(description string, deadline time.Time, command func() cfw.CommandPromise) error {
+	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+	timeout := time.Until(deadline)
+	if timeout <= 0 {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{No time left to %s} within %s", description, m.Timeout))
+		return cfw.ErrorCommandPromiseTimeout
+	}
+
+	if err := command().SubscribeOnErr(errorLog).Timeout(timeout).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to %s}", description))
+		errorLog.Flush()
+		return err
+	}
+	return nil
+}
+
+// cleanup unbinds and deletes the service instance after a failed step, ignoring errors as the instance
+// might not be bound or might not exist at all. The instance is cleaned up again on close if it could not be deleted
+func (m *ServiceMerkhet) cleanup(instance string) {
+	_ = m.Cli.UnbindService(m.AppProvider.AppName(), instance).Timeout(m.Timeout).Sync()
+	if err := m.Cli.DeleteService(instance).Timeout(m.Timeout).Sync(); err == nil {
+		m.deleted(instance)
+	}
+}
+
+// deleted marks the service instance as deleted, so it is not cleaned up on close
+func (m *ServiceMerkhet) deleted(instance string) {
+	m.lock.Lock()
+	delete(m.pending, instance)
+	m.lock.Unlock()
+}
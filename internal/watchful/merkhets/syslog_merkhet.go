@@ -0,0 +1,331 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var (
+	// SyslogDrainTimeout is the maximum duration the syslog merkhet waits for the first message after binding the drain
+	SyslogDrainTimeout = 2 * time.Minute
+
+	// MaxSyslogMessageSize is the maximum size in bytes of a single syslog message the syslog merkhet accepts
+	MaxSyslogMessageSize = 64 * 1024
+)
+
+// SyslogSettings are the settings of the syslog-functionality merkhet
+type SyslogSettings struct {
+	Protocol      string `yaml:"protocol"`
+	ListenAddress string `yaml:"listen-address"`
+	DrainURL      string `yaml:"drain-url"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "syslog-functionality",
+		DefaultHeartbeat: 30 * time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := SyslogSettings{}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if len(settings.DrainURL) < 1 {
+				return nil, fmt.Errorf("a drain-url reachable from the cloud foundry instance is required")
+			}
+
+			if settings.Protocol != "" && settings.Protocol != "tcp" && settings.Protocol != "udp" {
+				return nil, fmt.Errorf("unknown protocol %s, either tcp or udp is supported", settings.Protocol)
+			}
+
+			return NewSyslogMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base,
+				settings.Protocol, settings.ListenAddress, settings.DrainURL), nil
+		},
+	})
+}
+
+// SyslogMerkhet is an implementation of the Merkhet interface that tests if the logs of the sample app
+// arrive through a syslog drain at a listener run by watchful
+type SyslogMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	AppProvider   merkhet.AppProvider
+	BaseReference merkhet.Base
+	Protocol      string
+	ListenAddress string
+	DrainURL      string
+
+	listener        io.Closer
+	lock            *sync.Mutex
+	latestTimeStamp int64
+	lastTimeStamp   int64
+}
+
+// NewSyslogMerkhet creates a new instance of the merkhet implementation to check syslog drains
+func NewSyslogMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base, protocol string,
+	listenAddress string, drainURL string) *SyslogMerkhet {
+	return &SyslogMerkhet{
+		Cli:           cli,
+		AppProvider:   appProvider,
+		BaseReference: baseReference,
+		Protocol:      protocol,
+		ListenAddress: listenAddress,
+		DrainURL:      drainURL,
+		lock:          &sync.Mutex{},
+	}
+}
+
+// Install starts the syslog listener
+func (m *SyslogMerkhet) Install() error {
+	if len(m.DrainURL) < 1 {
+		m.Base().Logger().WriteString(logger.Error, "No syslog drain url configured")
+		return fmt.Errorf("the syslog merkhet requires a drain url reachable from the cloud foundry instance")
+	}
+
+	switch m.Protocol {
+	case "tcp", "":
+		listener, err := net.Listen("tcp", m.ListenAddress)
+		if err != nil {
+			m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not listen for syslog messages on %s", m.ListenAddress))
+			return err
+		}
+
+		m.listener = listener
+		go m.acceptConnections(listener)
+	case "udp":
+		connection, err := net.ListenPacket("udp", m.ListenAddress)
+		if err != nil {
+			m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not listen for syslog messages on %s", m.ListenAddress))
+			return err
+		}
+
+		m.listener = connection
+		go m.readPackets(connection)
+	default:
+		return fmt.Errorf("unsupported syslog protocol %s", m.Protocol)
+	}
+
+	m.Base().Logger().WriteString(logger.Info, fmt.Sprintf("Listening for syslog messages on %s", m.Address()))
+	return nil
+}
+
+// PostConnect pushes the sample app if not done and binds it to a syslog drain pointing at the listener
+func (m *SyslogMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.Push(m.Base().Logger())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not post-connect upstream sample-app, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	errorLog = logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Error))
+	serviceName := m.Base().Configuration().Name() + "-drain"
+	if err := m.Cli.CreateUserProvidedService(serviceName, m.DrainURL).SubscribeOnErr(errorLog).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not create syslog drain service %s", serviceName))
+		errorLog.Flush()
+		return err
+	}
+
+	if err := m.Cli.BindService(m.AppProvider.AppName(), serviceName).SubscribeOnErr(errorLog).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not bind syslog drain service %s", serviceName))
+		errorLog.Flush()
+		return err
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Waiting for the first message of the syslog drain")
+	for deadline := time.Now().Add(SyslogDrainTimeout); m.LatestTimeStamp() == 0; time.Sleep(time.Second) {
+		if time.Now().After(deadline) {
+			m.Base().Logger().WriteString(logger.Error, "Syslog drain did not deliver any message")
+			return fmt.Errorf("syslog drain did not deliver any message within %s", SyslogDrainTimeout)
+		}
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected syslog-merkhet")
+	return nil
+}
+
+// Execute tests if new logs arrived through the syslog drain since the last execution
+func (m *SyslogMerkhet) Execute() error {
+	timeStamp := m.LatestTimeStamp()
+	if timeStamp == 0 {
+		m.Base().Logger().WriteString(logger.Error, "Could not find timestamp in drained logs")
+		return fmt.Errorf("syslog drain did not deliver timestamps")
+	}
+
+	defer func() {
+		m.lastTimeStamp = timeStamp
+	}()
+
+	if timeStamp <= m.lastTimeStamp {
+		m.Base().Logger().WriteString(logger.Error, "Found timestamp is <= to previous one, no new logs")
+		return fmt.Errorf("found timestamp is <= to previous one, no new logs")
+	}
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Received drained logs successfully}"))
+	return nil
+}
+
+// Base returns the base reference of the merkhet
+func (m *SyslogMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// Close stops the syslog listener
+func (m *SyslogMerkhet) Close() error {
+	if m.listener == nil {
+		return nil
+	}
+	return m.listener.Close()
+}
+
+// Address returns the address the listener is bound to, or the configured address if it was not started yet
+func (m *SyslogMerkhet) Address() string {
+	switch listener := m.listener.(type) {
+	case net.Listener:
+		return listener.Addr().String()
+	case net.PacketConn:
+		return listener.LocalAddr().String()
+	default:
+		return m.ListenAddress
+	}
+}
+
+// LatestTimeStamp returns the latest timestamp received through the syslog drain
+func (m *SyslogMerkhet) LatestTimeStamp() int64 {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	return m.latestTimeStamp
+}
+
+// acceptConnections accepts tcp connections until the listener is closed
+func (m *SyslogMerkhet) acceptConnections(listener net.Listener) {
+	for {
+		connection, err := listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go func() {
+			defer connection.Close()
+			_ = ReadSyslogMessages(bufio.NewReader(connection), m.handleMessage)
+		}()
+	}
+}
+
+// readPackets reads udp packets until the connection is closed. Each packet contains a single message
+func (m *SyslogMerkhet) readPackets(connection net.PacketConn) {
+	buffer := make([]byte, MaxSyslogMessageSize)
+	for {
+		n, _, err := connection.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+		m.handleMessage(string(buffer[:n]))
+	}
+}
+
+// handleMessage stores the latest timestamp found in the syslog message
+func (m *SyslogMerkhet) handleMessage(message string) {
+	for _, match := range TimestampRegex.FindAllStringSubmatch(message, -1) {
+		timeStamp, err := strconv.ParseInt(match[1], 0, 64)
+		if err != nil {
+			continue
+		}
+
+		m.lock.Lock()
+		if timeStamp > m.latestTimeStamp {
+			m.latestTimeStamp = timeStamp
+		}
+		m.lock.Unlock()
+	}
+}
+
+// ReadSyslogMessages reads syslog messages framed as described in RFC 6587 from the reader.
+// Messages are either octet counted, prefixed by their length and a space, or terminated by a new line.
+// It stops with an error on a message that is empty or exceeds the maximum syslog message size
+func ReadSyslogMessages(reader *bufio.Reader, handle func(message string)) error {
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return err
+		}
+
+		if first[0] != '-' && (first[0] < '0' || first[0] > '9') { // Non-transparent framing
+			message, err := readSyslogFrame(reader, '\n', MaxSyslogMessageSize)
+			if len(message) > 0 {
+				handle(message)
+			}
+			if err != nil {
+				return err
+			}
+			continue
+		}
+
+		length, err := readSyslogFrame(reader, ' ', len(strconv.Itoa(MaxSyslogMessageSize))+1)
+		if err != nil {
+			return err
+		}
+
+		size, err := strconv.Atoi(length[:len(length)-1])
+		if err != nil {
+			return err
+		}
+
+		if size < 1 || size > MaxSyslogMessageSize {
+			return fmt.Errorf("octet count %d is not between 1 and %d", size, MaxSyslogMessageSize)
+		}
+
+		message := make([]byte, size)
+		if _, err := io.ReadFull(reader, message); err != nil {
+			return err
+		}
+		handle(string(message))
+	}
+}
+
+// readSyslogFrame reads until the delimiter, but fails once more than limit bytes are read without finding it
+func readSyslogFrame(reader *bufio.Reader, delimiter byte, limit int) (string, error) {
+	frame := make([]byte, 0)
+	for {
+		chunk, err := reader.ReadSlice(delimiter)
+		if len(frame)+len(chunk) > limit {
+			return "", fmt.Errorf("syslog frame exceeds the maximum size of %d bytes", limit)
+		}
+
+		frame = append(frame, chunk...)
+		if err != bufio.ErrBufferFull {
+			return string(frame), err
+		}
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+// TaskSettings are the settings of the cf-task-functionality merkhet
+type TaskSettings struct {
+	Command      string        `yaml:"command"`
+	Timeout      time.Duration `yaml:"timeout"`
+	PollInterval time.Duration `yaml:"poll-interval"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "cf-task-functionality",
+		DefaultHeartbeat: time.Minute,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := TaskSettings{Command: "sleep 1", Timeout: 2 * time.Minute, PollInterval: 2 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if settings.Command == "" {
+				return nil, fmt.Errorf("command must not be empty")
+			}
+
+			if settings.Timeout <= 0 || settings.PollInterval <= 0 {
+				return nil, fmt.Errorf("timeout and poll-interval have to be positive durations")
+			}
+
+			taskMerkhet := NewTaskMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base,
+				settings.Command, settings.Timeout)
+			taskMerkhet.PollInterval = settings.PollInterval
+			return taskMerkhet, nil
+		},
+	})
+}
+
+// TaskMerkhet is an implementation of the Merkhet interface that runs a task on the sample app and checks that
+// the task succeeds within a timeout
+type TaskMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	AppProvider   merkhet.AppProvider
+	BaseReference merkhet.Base
+	Command       string
+	Timeout       time.Duration
+	PollInterval  time.Duration
+}
+
+// NewTaskMerkhet creates a new instance of the merkhet implementation to check tasks
+func NewTaskMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base,
+	command string, timeout time.Duration) *TaskMerkhet {
+	return &TaskMerkhet{
+		Cli:           cli,
+		AppProvider:   appProvider,
+		BaseReference: baseReference,
+		Command:       command,
+		Timeout:       timeout,
+		PollInterval:  2 * time.Second,
+	}
+}
+
+// Install installs the merkhet, in this case does nothing
+func (m *TaskMerkhet) Install() error {
+	return nil
+}
+
+// PostConnect post connects the merkhet, in this case pushes the sample app if not done
+func (m *TaskMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.Push(m.Base().Logger())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not post-connect upstream sample-app, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected task-merkhet")
+	return nil
+}
+
+// Execute runs the task on the sample app and polls its state until it succeeded, failed or the timeout is reached
+func (m *TaskMerkhet) Execute() error {
+	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+	output := &bytes.Buffer{}
+	deadline := time.Now().Add(m.Timeout)
+
+	if err := m.Cli.RunTask(m.AppProvider.AppName(), m.Command, m.TaskName()).
+		SubscribeOnOut(output).SubscribeOnErr(errorLog).Timeout(m.Timeout).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to run the task}"))
+		errorLog.Flush()
+		return err
+	}
+
+	id, err := cfw.ParseTaskID(output.String())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to read the id of the task: } %s", err.Error()))
+		return err
+	}
+
+	task, err := m.awaitTask(id, deadline)
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to read the state of task %d: } %s", id, err.Error()))
+		return err
+	}
+
+	switch task.State {
+	case cfw.TaskSucceeded:
+		m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Task %d succeeded}", id))
+		return nil
+
+	case cfw.TaskFailed:
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Task %d failed}", id))
+		return fmt.Errorf("task %d of %s failed", id, m.AppProvider.AppName())
+
+	default:
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Task %d did not complete} within %s, state %s",
+			id, m.Timeout, task.State))
+		return fmt.Errorf("task %d of %s was %s after %s", id, m.AppProvider.AppName(), task.State, m.Timeout)
+	}
+}
+
+// TaskName returns the name the tasks of the merkhet are run under
+func (m *TaskMerkhet) TaskName() string {
+	return m.Base().Configuration().Name()
+}
+
+// Base returns the base reference of the merkhet
+func (m *TaskMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// awaitTask polls the tasks of the sample app until the task with the given id completed or the deadline is reached.
+// It returns the task read last, or the error of the last poll if the task could not be read at the deadline
+func (m *TaskMerkhet) awaitTask(id int, deadline time.Time) (cfw.Task, error) {
+	for {
+		task, err := m.readTask(id, time.Until(deadline))
+		if err == nil && task.Completed() {
+			return task, nil
+		}
+
+		if time.Now().Add(m.PollInterval).After(deadline) {
+			return task, err
+		}
+		time.Sleep(m.PollInterval)
+	}
+}
+
+// readTask reads the current state of the task with the given id, giving up after the timeout
+func (m *TaskMerkhet) readTask(id int, timeout time.Duration) (cfw.Task, error) {
+	if timeout <= 0 {
+		return cfw.Task{}, cfw.ErrorCommandPromiseTimeout
+	}
+
+	output := &bytes.Buffer{}
+	errorLog := logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Debug))
+	if err := m.Cli.Tasks(m.AppProvider.AppName()).SubscribeOnOut(output).SubscribeOnErr(errorLog).Timeout(timeout).Sync(); err != nil {
+		return cfw.Task{}, err
+	}
+
+	tasks, err := cfw.ParseTasks(output.String())
+	if err != nil {
+		return cfw.Task{}, err
+	}
+
+	for _, task := range tasks {
+		if task.ID == id {
+			return task, nil
+		}
+	}
+	return cfw.Task{}, fmt.Errorf("the tasks of %s contain no task with id %d", m.AppProvider.AppName(), id)
+}
@@ -0,0 +1,197 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+const (
+	// SampleAppModeVariable is the environment variable that selects the mode of the sample app
+	SampleAppModeVariable = "WATCHFUL_MODE"
+
+	// SampleAppTCPEchoMode is the mode in which the sample app echoes everything it receives over raw tcp
+	SampleAppTCPEchoMode = "tcp-echo"
+)
+
+// TCPSettings are the settings of the tcp-availability merkhet
+type TCPSettings struct {
+	Domain  string        `yaml:"domain"`
+	Port    int           `yaml:"port"`
+	Payload string        `yaml:"payload"`
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "tcp-availability",
+		DefaultHeartbeat: time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := TCPSettings{Payload: "watchful", Timeout: 10 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			if len(settings.Domain) < 1 {
+				return nil, fmt.Errorf("a tcp domain of the cloud foundry instance is required")
+			}
+
+			if settings.Port < 1 || settings.Port > 65535 {
+				return nil, fmt.Errorf("port %d is not a valid port of the tcp domain", settings.Port)
+			}
+
+			if len(settings.Payload) < 1 {
+				return nil, fmt.Errorf("payload must not be empty")
+			}
+
+			if settings.Timeout <= 0 {
+				return nil, fmt.Errorf("timeout has to be a positive duration")
+			}
+
+			tcpMerkhet := NewTCPMerkhet(context.Dependencies.CLI, context.Dependencies.AppProvider, context.Base,
+				settings.Domain, settings.Port, settings.Timeout)
+			tcpMerkhet.Payload = []byte(settings.Payload)
+			return tcpMerkhet, nil
+		},
+	})
+}
+
+// TCPMerkhet is an implementation of the Merkhet interface that round-trips a payload through a tcp route.
+// The merkhet pushes its own app, named after the merkhet, that runs the sample app in tcp echo mode
+type TCPMerkhet struct {
+	Cli           cfw.CloudFoundryCLI
+	AppProvider   merkhet.AppProvider
+	BaseReference merkhet.Base
+	Domain        string
+	Port          int
+	Payload       []byte
+	Timeout       time.Duration
+}
+
+// NewTCPMerkhet creates a new instance of the merkhet implementation to check tcp routes
+func NewTCPMerkhet(cli cfw.CloudFoundryCLI, appProvider merkhet.AppProvider, baseReference merkhet.Base, domain string,
+	port int, timeout time.Duration) *TCPMerkhet {
+	return &TCPMerkhet{
+		Cli:           cli,
+		AppProvider:   appProvider,
+		BaseReference: baseReference,
+		Domain:        domain,
+		Port:          port,
+		Payload:       []byte("watchful"),
+		Timeout:       timeout,
+	}
+}
+
+// Install installs the merkhet, in this case does nothing
+func (m *TCPMerkhet) Install() error {
+	return nil
+}
+
+// PostConnect pushes the app of the merkhet, switches it to tcp echo mode and maps the tcp route to it
+func (m *TCPMerkhet) PostConnect() error {
+	infoLog, errorLog, err := m.AppProvider.ForcePush(m.Base().Logger(), m.AppName())
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not push the app of the tcp-merkhet, printing logs")
+		infoLog.Flush()
+		errorLog.Flush()
+		return err
+	}
+
+	errorLog = logger.NewByteBufferCachedLogger(m.Base().Logger().ReportingOn(logger.Error))
+	if err := m.Cli.SetEnv(m.AppName(), SampleAppModeVariable, SampleAppTCPEchoMode).SubscribeOnErr(errorLog).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not switch the app of the tcp-merkhet to tcp echo mode")
+		errorLog.Flush()
+		return err
+	}
+
+	if err := m.Cli.Restart(m.AppName()).SubscribeOnErr(errorLog).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, "Could not restart the app of the tcp-merkhet")
+		errorLog.Flush()
+		return err
+	}
+
+	if err := m.Cli.MapTCPRoute(m.AppName(), m.Domain, m.Port).SubscribeOnErr(errorLog).Sync(); err != nil {
+		m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not map the tcp route %s", m.Address()))
+		errorLog.Flush()
+		return err
+	}
+
+	m.Base().Logger().WriteString(logger.Info, "Post-Connected tcp-merkhet")
+	return nil
+}
+
+// Execute opens a new connection to the tcp route and checks that the payload is echoed back within the timeout
+func (m *TCPMerkhet) Execute() error {
+	connection, err := net.DialTimeout("tcp", m.Address(), m.Timeout)
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to connect} to %s: %s", m.Address(), err.Error()))
+		return err
+	}
+	defer connection.Close()
+
+	if err := connection.SetDeadline(time.Now().Add(m.Timeout)); err != nil {
+		return err
+	}
+
+	if _, err := connection.Write(m.Payload); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to send payload} to %s: %s", m.Address(), err.Error()))
+		return err
+	}
+
+	echo := make([]byte, len(m.Payload))
+	if _, err := io.ReadFull(connection, echo); err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to receive payload} from %s: %s", m.Address(), err.Error()))
+		return err
+	}
+
+	if !bytes.Equal(echo, m.Payload) {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Received a different payload} from %s", m.Address()))
+		return fmt.Errorf("%s echoed %q instead of %q", m.Address(), echo, m.Payload)
+	}
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Round-tripped payload} through %s", m.Address()))
+	return nil
+}
+
+// AppName returns the name of the app the tcp route is mapped to
+func (m *TCPMerkhet) AppName() string {
+	return m.Base().Configuration().Name()
+}
+
+// Address returns the address of the tcp route
+func (m *TCPMerkhet) Address() string {
+	return net.JoinHostPort(m.Domain, strconv.Itoa(m.Port))
+}
+
+// Base returns the base reference of the merkhet
+func (m *TCPMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
@@ -0,0 +1,229 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhets
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gonvenience/bunt"
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+const (
+	// PasswordGrant requests tokens with the credentials of the cloud foundry user
+	PasswordGrant = "password"
+
+	// ClientCredentialsGrant requests tokens with the credentials of the uaa client
+	ClientCredentialsGrant = "client_credentials"
+)
+
+// UAASettings are the settings of the uaa-token-issuance merkhet
+type UAASettings struct {
+	GrantType     string        `yaml:"grant-type"`
+	ClientID      string        `yaml:"client-id"`
+	ClientSecret  string        `yaml:"client-secret"`
+	TokenEndpoint string        `yaml:"token-endpoint"`
+	Timeout       time.Duration `yaml:"timeout"`
+}
+
+func init() {
+	merkhet.Register(merkhet.Definition{
+		Name:             "uaa-token-issuance",
+		DefaultHeartbeat: 5 * time.Second,
+		Factory: func(context merkhet.FactoryContext) (merkhet.Merkhet, error) {
+			settings := UAASettings{GrantType: PasswordGrant, ClientID: "cf", Timeout: 30 * time.Second}
+			if err := context.Settings.Decode(&settings); err != nil {
+				return nil, err
+			}
+
+			switch settings.GrantType {
+			case PasswordGrant:
+			case ClientCredentialsGrant:
+				if len(settings.ClientSecret) < 1 {
+					return nil, fmt.Errorf("the client_credentials grant requires a client-secret")
+				}
+			default:
+				return nil, fmt.Errorf("unknown grant-type %s, either password or client_credentials is supported", settings.GrantType)
+			}
+
+			if settings.Timeout <= 0 {
+				return nil, fmt.Errorf("timeout %s has to be a positive duration", settings.Timeout.String())
+			}
+
+			httpClient := &http.Client{
+				Timeout: settings.Timeout,
+				Transport: &http.Transport{
+					Proxy:           http.ProxyFromEnvironment,
+					TLSClientConfig: &tls.Config{InsecureSkipVerify: context.Dependencies.SkipSSLValidation},
+				},
+			}
+
+			uaaMerkhet := NewUAAMerkhet(context.Base, httpClient, context.Dependencies.APIEndpoint, context.Dependencies.Credentials)
+			uaaMerkhet.GrantType = settings.GrantType
+			uaaMerkhet.ClientID = settings.ClientID
+			uaaMerkhet.ClientSecret = settings.ClientSecret
+			uaaMerkhet.TokenEndpoint = strings.TrimSuffix(settings.TokenEndpoint, "/")
+			return uaaMerkhet, nil
+		},
+	})
+}
+
+// UAAMerkhet is an implementation of the Merkhet interface that repeatedly requests a token from the uaa
+type UAAMerkhet struct {
+	BaseReference merkhet.Base
+	HTTPClient    *http.Client
+	APIEndpoint   string
+	TokenEndpoint string
+	GrantType     string
+	ClientID      string
+	ClientSecret  string
+	Credentials   cfw.CloudFoundryCertificate
+}
+
+// NewUAAMerkhet creates a new instance of the merkhet implementation to check the token issuance of the uaa.
+// By default tokens are requested with the password grant of the cf client
+func NewUAAMerkhet(baseReference merkhet.Base, httpClient *http.Client, apiEndpoint string, credentials cfw.CloudFoundryCertificate) *UAAMerkhet {
+	return &UAAMerkhet{
+		BaseReference: baseReference,
+		HTTPClient:    httpClient,
+		APIEndpoint:   strings.TrimSuffix(apiEndpoint, "/"),
+		GrantType:     PasswordGrant,
+		ClientID:      "cf",
+		Credentials:   credentials,
+	}
+}
+
+// Install installs the merkhet, in this case does nothing
+func (m *UAAMerkhet) Install() error {
+	return nil
+}
+
+// PostConnect looks up the token endpoint of the uaa through the api endpoint, if it was not configured
+func (m *UAAMerkhet) PostConnect() error {
+	if len(m.TokenEndpoint) < 1 {
+		tokenEndpoint, err := m.discoverTokenEndpoint()
+		if err != nil {
+			m.Base().Logger().WriteString(logger.Error, fmt.Sprintf("Could not look up the uaa of %s", m.APIEndpoint))
+			return err
+		}
+		m.TokenEndpoint = tokenEndpoint
+	}
+
+	m.Base().Logger().WriteString(logger.Info, fmt.Sprintf("Post-Connected uaa-merkhet against %s", m.TokenEndpoint))
+	return nil
+}
+
+// Execute requests a new token from the uaa
+func (m *UAAMerkhet) Execute() error {
+	form := url.Values{}
+	form.Set("grant_type", m.GrantType)
+	if m.GrantType == PasswordGrant {
+		form.Set("username", m.Credentials.Username)
+		form.Set("password", m.Credentials.Password)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, m.TokenEndpoint+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	request.Header.Set("Accept", "application/json")
+	request.SetBasicAuth(url.QueryEscape(m.ClientID), url.QueryEscape(m.ClientSecret))
+
+	response, err := m.HTTPClient.Do(request)
+	if err != nil {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to request token: } %s", err.Error()))
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		_, _ = io.Copy(ioutil.Discard, response.Body)
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to request token: } Response Code: %d", response.StatusCode))
+		return fmt.Errorf("the uaa %s returned status code %d", m.TokenEndpoint, response.StatusCode)
+	}
+
+	token := struct {
+		AccessToken string `json:"access_token"`
+	}{}
+	if err := json.NewDecoder(response.Body).Decode(&token); err != nil || len(token.AccessToken) < 1 {
+		m.Base().Logger().WriteString(logger.Error, bunt.Sprintf("Red{Failed to request token: } Response contains no access token"))
+		return fmt.Errorf("the uaa %s did not respond with an access token", m.TokenEndpoint)
+	}
+
+	m.Base().Logger().WriteString(logger.Debug, bunt.Sprintf("SpringGreen{Requested token successfully}"))
+	return nil
+}
+
+// Base returns the base reference of the merkhet
+func (m *UAAMerkhet) Base() merkhet.Base {
+	return m.BaseReference
+}
+
+// discoverTokenEndpoint reads the uaa link of the api root endpoint and falls back to the token endpoint of /v2/info
+func (m *UAAMerkhet) discoverTokenEndpoint() (string, error) {
+	root := struct {
+		Links struct {
+			UAA struct {
+				Href string `json:"href"`
+			} `json:"uaa"`
+		} `json:"links"`
+	}{}
+	if err := m.getJSON(m.APIEndpoint+"/", &root); err == nil && len(root.Links.UAA.Href) > 0 {
+		return strings.TrimSuffix(root.Links.UAA.Href, "/"), nil
+	}
+
+	info := struct {
+		TokenEndpoint string `json:"token_endpoint"`
+	}{}
+	if err := m.getJSON(m.APIEndpoint+"/v2/info", &info); err != nil {
+		return "", err
+	}
+
+	if len(info.TokenEndpoint) < 1 {
+		return "", fmt.Errorf("the api %s does not link to an uaa", m.APIEndpoint)
+	}
+	return strings.TrimSuffix(info.TokenEndpoint, "/"), nil
+}
+
+// getJSON requests the location and decodes the json response into the target
+func (m *UAAMerkhet) getJSON(location string, target interface{}) error {
+	response, err := m.HTTPClient.Get(location)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status code %d", location, response.StatusCode)
+	}
+	return json.NewDecoder(response.Body).Decode(target)
+}
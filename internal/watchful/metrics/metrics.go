@@ -0,0 +1,268 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var (
+	// DurationBuckets defines the upper bounds in seconds of the merkhet execution duration histogram
+	DurationBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+)
+
+// Exporter is a http handler exposing the live state of the merkhets in the prometheus text format.
+// The counters and histograms of a merkhet are updated whenever it records a sample, so a scrape does not have to
+// walk the samples. Its validity is only evaluated again if it recorded new samples since the previous scrape
+type Exporter struct {
+	Pool      merkhet.Pool
+	taskIndex int64
+	lock      *sync.Mutex
+	merkhets  map[merkhet.Base]*merkhetMetrics
+}
+
+// merkhetMetrics contains the running counters of a single merkhet
+type merkhetMetrics struct {
+	lock        *sync.Mutex
+	runs        int
+	failures    int
+	durations   []int
+	durationSum float64
+	executions  int
+	valid       bool
+	dirty       bool
+	targets     []string
+	targetRuns  map[string]*targetMetrics
+}
+
+// targetMetrics contains the running counters of a single target of a merkhet
+type targetMetrics struct {
+	runs     int
+	failures int
+	valid    bool
+}
+
+// NewExporter creates a new exporter for the merkhets managed by the given pool
+func NewExporter(pool merkhet.Pool) *Exporter {
+	return &Exporter{Pool: pool, lock: &sync.Mutex{}, merkhets: make(map[merkhet.Base]*merkhetMetrics)}
+}
+
+// SetTaskIndex updates the index of the currently executing task
+func (e *Exporter) SetTaskIndex(index int) {
+	atomic.StoreInt64(&e.taskIndex, int64(index))
+}
+
+// TaskIndex returns the index of the currently executing task
+func (e *Exporter) TaskIndex() int {
+	return int(atomic.LoadInt64(&e.taskIndex))
+}
+
+// Handler returns a http handler serving the metrics under /metrics
+func (e *Exporter) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", e)
+	return mux
+}
+
+// ServeHTTP renders the metrics of every merkhet in the prometheus text format
+func (e *Exporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	runs := &bytes.Buffer{}
+	durations := &bytes.Buffer{}
+	beating := &bytes.Buffer{}
+	valid := &bytes.Buffer{}
+	targetRuns := &bytes.Buffer{}
+	targetValid := &bytes.Buffer{}
+
+	for _, heartbeat := range e.Pool.Heartbeats() {
+		base := heartbeat.Worker().Merkhet().Base()
+		name := escapeLabelValue(base.Configuration().Name())
+		metrics := e.metricsOf(base)
+
+		metrics.lock.Lock()
+		fmt.Fprintf(runs, "watchful_merkhet_runs_total{merkhet=\"%s\",result=\"success\"} %d\n", name, metrics.runs-metrics.failures)
+		fmt.Fprintf(runs, "watchful_merkhet_runs_total{merkhet=\"%s\",result=\"failure\"} %d\n", name, metrics.failures)
+
+		writeHistogram(durations, name, metrics)
+
+		fmt.Fprintf(beating, "watchful_merkhet_beating{merkhet=\"%s\"} %d\n", name, boolAsInt(heartbeat.IsBeating()))
+		fmt.Fprintf(valid, "watchful_merkhet_valid{merkhet=\"%s\"} %d\n", name, boolAsInt(metrics.valid))
+
+		for _, target := range metrics.targets {
+			counters := metrics.targetRuns[target]
+			target = escapeLabelValue(target)
+
+			fmt.Fprintf(targetRuns, "watchful_merkhet_target_runs_total{merkhet=\"%s\",target=\"%s\",result=\"success\"} %d\n",
+				name, target, counters.runs-counters.failures)
+			fmt.Fprintf(targetRuns, "watchful_merkhet_target_runs_total{merkhet=\"%s\",target=\"%s\",result=\"failure\"} %d\n",
+				name, target, counters.failures)
+			fmt.Fprintf(targetValid, "watchful_merkhet_target_valid{merkhet=\"%s\",target=\"%s\"} %d\n",
+				name, target, boolAsInt(counters.valid))
+		}
+		metrics.lock.Unlock()
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintln(w, "# HELP watchful_task_index The index of the currently executing task, 0 before the first task.")
+	fmt.Fprintln(w, "# TYPE watchful_task_index gauge")
+	fmt.Fprintf(w, "watchful_task_index %d\n", e.TaskIndex())
+
+	fmt.Fprintln(w, "# HELP watchful_merkhet_runs_total The amount of runs of a merkhet by their result.")
+	fmt.Fprintln(w, "# TYPE watchful_merkhet_runs_total counter")
+	_, _ = runs.WriteTo(w)
+
+	fmt.Fprintln(w, "# HELP watchful_merkhet_execution_duration_seconds The duration of the executions of a merkhet.")
+	fmt.Fprintln(w, "# TYPE watchful_merkhet_execution_duration_seconds histogram")
+	_, _ = durations.WriteTo(w)
+
+	fmt.Fprintln(w, "# HELP watchful_merkhet_beating Whether the heart of a merkhet is currently beating.")
+	fmt.Fprintln(w, "# TYPE watchful_merkhet_beating gauge")
+	_, _ = beating.WriteTo(w)
+
+	fmt.Fprintln(w, "# HELP watchful_merkhet_valid Whether the runs of a merkhet are currently within its threshold.")
+	fmt.Fprintln(w, "# TYPE watchful_merkhet_valid gauge")
+	_, _ = valid.WriteTo(w)
+
+	fmt.Fprintln(w, "# HELP watchful_merkhet_target_runs_total The amount of runs of a single target of a merkhet by their result.")
+	fmt.Fprintln(w, "# TYPE watchful_merkhet_target_runs_total counter")
+	_, _ = targetRuns.WriteTo(w)
+
+	fmt.Fprintln(w, "# HELP watchful_merkhet_target_valid Whether the runs of a single target of a merkhet are currently within its threshold.")
+	fmt.Fprintln(w, "# TYPE watchful_merkhet_target_valid gauge")
+	_, _ = targetValid.WriteTo(w)
+}
+
+// metricsOf returns the running counters of the merkhet with the given base, which start observing its samples on
+// the first call. The counters of a base that can not be observed are built from its result on every call.
+// The validity is evaluated again if the merkhet recorded samples since the previous call
+func (e *Exporter) metricsOf(base merkhet.Base) *merkhetMetrics {
+	e.lock.Lock()
+	metrics, known := e.merkhets[base]
+	if !known {
+		metrics = newMerkhetMetrics()
+		if observable, ok := base.(merkhet.Observable); ok {
+			e.merkhets[base] = metrics
+			observable.Observe(metrics.record)
+		} else {
+			for _, sample := range base.NewResultSet().Samples() {
+				metrics.record(sample)
+			}
+		}
+	}
+	e.lock.Unlock()
+
+	metrics.lock.Lock()
+	dirty := metrics.dirty
+	metrics.dirty = false
+	metrics.lock.Unlock()
+
+	if dirty {
+		result := base.NewResultSet()
+
+		metrics.lock.Lock()
+		metrics.valid = result.Valid()
+		for _, target := range result.Targets() {
+			if counters, ok := metrics.targetRuns[target]; ok {
+				counters.valid = result.ForTarget(target).Valid()
+			}
+		}
+		metrics.lock.Unlock()
+	}
+	return metrics
+}
+
+// newMerkhetMetrics creates the running counters of a merkhet that did not record any sample yet
+func newMerkhetMetrics() *merkhetMetrics {
+	return &merkhetMetrics{
+		lock:       &sync.Mutex{},
+		durations:  make([]int, len(DurationBuckets)),
+		valid:      true,
+		dirty:      true,
+		targets:    make([]string, 0),
+		targetRuns: make(map[string]*targetMetrics),
+	}
+}
+
+// record updates the counters with a sample the merkhet recorded
+func (m *merkhetMetrics) record(sample merkhet.Sample) {
+	defer m.lock.Unlock()
+
+	m.lock.Lock()
+	m.runs += sample.Runs()
+	m.failures += sample.FailedRuns()
+	m.dirty = true
+
+	seconds := sample.Duration.Seconds()
+	m.durationSum += seconds
+	m.executions++
+	for i, bound := range DurationBuckets {
+		if seconds <= bound {
+			m.durations[i]++
+			break
+		}
+	}
+
+	if len(sample.Target) > 0 {
+		counters, ok := m.targetRuns[sample.Target]
+		if !ok {
+			counters = &targetMetrics{valid: true}
+			m.targetRuns[sample.Target] = counters
+			m.targets = append(m.targets, sample.Target)
+		}
+		counters.runs += sample.Runs()
+		counters.failures += sample.FailedRuns()
+	}
+}
+
+// writeHistogram writes the cumulative duration histogram of the executions of the merkhet
+func writeHistogram(w *bytes.Buffer, name string, metrics *merkhetMetrics) {
+	count := 0
+	for i, bound := range DurationBuckets {
+		count += metrics.durations[i]
+		fmt.Fprintf(w, "watchful_merkhet_execution_duration_seconds_bucket{merkhet=\"%s\",le=\"%s\"} %d\n",
+			name, strconv.FormatFloat(bound, 'g', -1, 64), count)
+	}
+	fmt.Fprintf(w, "watchful_merkhet_execution_duration_seconds_bucket{merkhet=\"%s\",le=\"+Inf\"} %d\n", name, metrics.executions)
+	fmt.Fprintf(w, "watchful_merkhet_execution_duration_seconds_sum{merkhet=\"%s\"} %s\n", name, strconv.FormatFloat(metrics.durationSum, 'g', -1, 64))
+	fmt.Fprintf(w, "watchful_merkhet_execution_duration_seconds_count{merkhet=\"%s\"} %d\n", name, metrics.executions)
+}
+
+// escapeLabelValue escapes the characters not allowed in a prometheus label value
+func escapeLabelValue(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// boolAsInt converts the boolean into the numeric value used by prometheus
+func boolAsInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,124 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package metrics_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+
+	"github.com/homeport/watchful/internal/watchful/metrics"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var _ = Describe("Metrics testing", func() {
+	Context("Tests the prometheus endpoint exposing the merkhet state", func() {
+
+		var (
+			pool     *merkhet.SimplePool
+			exporter *metrics.Exporter
+			server   *httptest.Server
+		)
+
+		scrape := func() string {
+			response, err := http.Get(server.URL + "/metrics")
+			Expect(err).To(BeNil())
+			defer response.Body.Close()
+
+			Expect(response.StatusCode).To(BeEquivalentTo(http.StatusOK))
+			body, err := ioutil.ReadAll(response.Body)
+			Expect(err).To(BeNil())
+			return string(body)
+		}
+
+		BeforeEach(func() {
+			pool = merkhet.NewPool()
+			exporter = metrics.NewExporter(pool)
+			server = httptest.NewServer(exporter.Handler())
+		})
+
+		AfterEach(func() {
+			server.Close()
+			pool.Shutdown()
+		})
+
+		It("should expose the run counters and durations of each merkhet", func() {
+			base := merkhet.NewSimpleBase(nil, merkhet.NewFlatConfiguration("http-availability", 0))
+			base.Record(merkhet.NewSample(time.Now(), 20*time.Millisecond, nil))
+			base.Record(merkhet.NewSample(time.Now(), 2*time.Second, nil))
+			base.Record(merkhet.NewSample(time.Now(), 300*time.Millisecond, fmt.Errorf("connection refused")))
+			pool.StartWorker(&MerkhetMock{BaseReference: base}, time.Second, nil)
+
+			body := scrape()
+			Expect(body).To(ContainSubstring(`watchful_merkhet_runs_total{merkhet="http-availability",result="success"} 2`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_runs_total{merkhet="http-availability",result="failure"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_execution_duration_seconds_bucket{merkhet="http-availability",le="0.025"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_execution_duration_seconds_bucket{merkhet="http-availability",le="0.5"} 2`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_execution_duration_seconds_bucket{merkhet="http-availability",le="+Inf"} 3`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_execution_duration_seconds_sum{merkhet="http-availability"} 2.32`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_execution_duration_seconds_count{merkhet="http-availability"} 3`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_valid{merkhet="http-availability"} 0`))
+		})
+
+		It("should update the counters with the samples recorded after the first scrape", func() {
+			base := merkhet.NewSimpleBase(nil, merkhet.NewFlatConfiguration("http-availability", 0))
+			base.Record(merkhet.NewTargetSample("login", time.Now(), 20*time.Millisecond, nil))
+			pool.StartWorker(&MerkhetMock{BaseReference: base}, time.Second, nil)
+
+			body := scrape()
+			Expect(body).To(ContainSubstring(`watchful_merkhet_runs_total{merkhet="http-availability",result="success"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_target_valid{merkhet="http-availability",target="login"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_valid{merkhet="http-availability"} 1`))
+
+			base.Record(merkhet.NewTargetSample("login", time.Now(), 20*time.Millisecond, fmt.Errorf("status 502")))
+			base.Record(merkhet.NewTargetSample("logout", time.Now(), 20*time.Millisecond, nil))
+
+			body = scrape()
+			Expect(body).To(ContainSubstring(`watchful_merkhet_runs_total{merkhet="http-availability",result="success"} 2`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_runs_total{merkhet="http-availability",result="failure"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_execution_duration_seconds_count{merkhet="http-availability"} 3`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_target_runs_total{merkhet="http-availability",target="login",result="failure"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_target_runs_total{merkhet="http-availability",target="logout",result="success"} 1`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_target_valid{merkhet="http-availability",target="login"} 0`))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_valid{merkhet="http-availability"} 0`))
+		})
+
+		It("should expose the current task and the beating hearts", func() {
+			base := merkhet.NewSimpleBase(nil, merkhet.NewFlatConfiguration("app-pushability", 0))
+			pool.StartWorker(&MerkhetMock{BaseReference: base}, time.Hour, merkhet.ConsumeSync(func(m merkhet.Merkhet, future merkhet.Future) {
+				future.Complete(nil)
+			}))
+
+			exporter.SetTaskIndex(2)
+			Expect(scrape()).To(ContainSubstring(`watchful_merkhet_beating{merkhet="app-pushability"} 0`))
+
+			pool.StartHeartbeats()
+			body := scrape()
+			Expect(body).To(ContainSubstring("watchful_task_index 2"))
+			Expect(body).To(ContainSubstring(`watchful_merkhet_beating{merkhet="app-pushability"} 1`))
+		})
+	})
+})
@@ -0,0 +1,234 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package report
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/homeport/watchful/internal/watchful/cfg"
+	"github.com/homeport/watchful/pkg/merkhet"
+	"gopkg.in/yaml.v2"
+)
+
+// Recorder collects the outcome of a watchful run into a report
+type Recorder struct {
+	report Report
+	lock   *sync.Mutex
+}
+
+// NewRecorder creates a new recorder for a run using the given configuration
+func NewRecorder(config *cfg.WatchfulConfig) *Recorder {
+	summary := ConfigurationSummary{
+		APIEndPoint: config.CloudFoundryConfig.APIEndPoint,
+		Domain:      config.CloudFoundryConfig.Domain,
+		Merkhets:    make([]MerkhetSummary, 0, len(config.MerkhetConfigurations)),
+	}
+
+	for _, c := range config.MerkhetConfigurations {
+		merkhetSummary := MerkhetSummary{Name: c.Name, Type: c.GetType(), Threshold: c.Threshold, LatencyThreshold: c.LatencyThreshold}
+		if c.HeartbeatRate != nil {
+			merkhetSummary.Heartbeat = c.HeartbeatRate.String()
+		}
+		summary.Merkhets = append(summary.Merkhets, merkhetSummary)
+	}
+
+	return &Recorder{
+		report: Report{
+			Configuration: summary,
+			Start:         time.Now(),
+			Tasks:         make([]Task, 0, len(config.TaskConfigurations)),
+		},
+		lock: &sync.Mutex{},
+	}
+}
+
+// StartTask records the start of the task with the given index
+func (r *Recorder) StartTask(index int, configuration cfg.TaskConfiguration) {
+	defer r.lock.Unlock()
+
+	r.lock.Lock()
+	r.report.Tasks = append(r.report.Tasks, Task{
+		Index:            index,
+		Command:          configuration.Executable,
+		Arguments:        configuration.Parameters,
+		MerkhetWhitelist: configuration.MerkhetWhitelist,
+		MerkhetBlacklist: configuration.MerkhetBlacklist,
+		Start:            time.Now(),
+		Merkhets:         make([]MerkhetResult, 0),
+	})
+}
+
+// FinishTask records the end of the task with the given index and the error it failed with, if any
+func (r *Recorder) FinishTask(index int, err error) {
+	defer r.lock.Unlock()
+
+	r.lock.Lock()
+	if task := r.task(index); task != nil {
+		task.End = time.Now()
+		if err != nil {
+			task.Error = err.Error()
+		}
+	}
+}
+
+// RecordMerkhet records the verdict of a merkhet for the task with the given index
+func (r *Recorder) RecordMerkhet(index int, configuration merkhet.Configuration, result merkhet.Result) {
+	defer r.lock.Unlock()
+
+	r.lock.Lock()
+	task := r.task(index)
+	if task == nil {
+		return
+	}
+
+	samples := merkhet.SamplesOfTask(result.Samples(), index)
+	merkhetResult := newMerkhetResult(configuration.Name(), configuration.ThresholdAsString(), result, samples)
+	for _, target := range result.Targets() {
+		merkhetResult.Targets = append(merkhetResult.Targets, newMerkhetResult(target, configuration.ThresholdAsString(),
+			result.ForTarget(target), merkhet.SamplesOfTarget(samples, target)))
+	}
+
+	task.Merkhets = append(task.Merkhets, merkhetResult)
+}
+
+// newMerkhetResult creates the report of a merkhet result, the downtime and the failures are taken from the samples
+func newMerkhetResult(name string, threshold string, result merkhet.Result, samples []merkhet.Sample) MerkhetResult {
+	downtime := merkhet.NewDowntime(samples)
+
+	merkhetResult := MerkhetResult{
+		Name:           name,
+		Threshold:      threshold,
+		TotalRuns:      result.TotalRuns(),
+		SuccessfulRuns: result.SuccessfulRuns(),
+		FailedRuns:     result.FailedRuns(),
+		SkippedRuns:    merkhet.CountSkipped(samples),
+		Valid:          result.Valid(),
+		Downtime: Downtime{
+			Total:   downtime.Total().String(),
+			Longest: downtime.Longest().String(),
+		},
+	}
+
+	if len(samples) > 0 {
+		latency := merkhet.NewLatency(samples)
+		merkhetResult.Latency = &Latency{P50: latency.P50.String(), P95: latency.P95.String(), P99: latency.P99.String()}
+	}
+
+	if instances := merkhet.InstanceDistribution(samples); len(instances) > 0 {
+		merkhetResult.Instances = instances
+	}
+
+	for _, disconnect := range merkhet.DisconnectsOf(samples) {
+		reported := Disconnect{Connection: disconnect.Connection, Time: disconnect.Time, Reason: disconnect.Reason}
+		if !disconnect.Reconnected.IsZero() {
+			reported.ReconnectTime = disconnect.ReconnectTime().String()
+		}
+		merkhetResult.Disconnects = append(merkhetResult.Disconnects, reported)
+	}
+
+	merkhetResult.Interruptions = merkhet.TotalInterruptions(samples)
+
+	if loss := merkhet.TotalLogLoss(samples); loss != nil {
+		merkhetResult.LogLoss = &LogLoss{
+			Expected:   loss.Expected,
+			Received:   loss.Received,
+			Lost:       loss.Lost,
+			OutOfOrder: loss.OutOfOrder,
+			Truncated:  loss.Truncated,
+			Rate:       fmt.Sprintf("%.2f%%", loss.Rate()),
+		}
+	}
+
+	for _, outage := range downtime.Outages {
+		merkhetResult.Downtime.Outages = append(merkhetResult.Downtime.Outages, Outage{
+			Start:       outage.Start,
+			End:         outage.End,
+			LastFailure: outage.LastFailure,
+			Duration:    outage.Duration().String(),
+			Failures:    outage.Failures,
+			Target:      outage.Target,
+		})
+	}
+
+	for _, sample := range samples {
+		if sample.Failed() {
+			merkhetResult.Failures = append(merkhetResult.Failures, Failure{
+				Start:    sample.Start,
+				Duration: sample.Duration.String(),
+				Error:    sample.Error,
+				Target:   sample.Target,
+			})
+		}
+	}
+
+	return merkhetResult
+}
+
+// Finish completes the report with the error the run failed with, if any
+func (r *Recorder) Finish(err error) *Report {
+	defer r.lock.Unlock()
+
+	r.lock.Lock()
+	r.report.End = time.Now()
+	r.report.Valid = err == nil
+	if err != nil {
+		r.report.Error = err.Error()
+	}
+
+	report := r.report
+	return &report
+}
+
+// task returns the task with the given index or nil if it was not started yet
+func (r *Recorder) task(index int) *Task {
+	for i := range r.report.Tasks {
+		if r.report.Tasks[i].Index == index {
+			return &r.report.Tasks[i]
+		}
+	}
+	return nil
+}
+
+// WriteToFile writes the report to the given file.
+// The report is written as json if the file ends with .json, otherwise as yaml
+func (r *Report) WriteToFile(file string) error {
+	var (
+		content []byte
+		err     error
+	)
+
+	if strings.ToLower(filepath.Ext(file)) == ".json" {
+		content, err = json.MarshalIndent(r, "", "  ")
+	} else {
+		content, err = yaml.Marshal(r)
+	}
+
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, content, 0644)
+}
@@ -0,0 +1,173 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package report
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// JUnitTestSuites is the root element of a JUnit XML report
+type JUnitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
+}
+
+// JUnitTestSuite represents a single task of the run
+type JUnitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	TestCases []JUnitTestCase `xml:"testcase"`
+}
+
+// JUnitTestCase represents the verdict of a single merkhet in a task
+type JUnitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *JUnitFailure `xml:"failure,omitempty"`
+	Error     *JUnitFailure `xml:"error,omitempty"`
+}
+
+// JUnitFailure contains the reason a test case failed
+type JUnitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Content string `xml:",chardata"`
+}
+
+// NewJUnitTestSuites converts the report into JUnit test suites.
+// Every task is a test suite and every merkhet verdict of the task is a test case of it
+func NewJUnitTestSuites(report *Report) *JUnitTestSuites {
+	suites := &JUnitTestSuites{
+		Name:   "watchful",
+		Time:   junitSeconds(report.End.Sub(report.Start)),
+		Suites: make([]JUnitTestSuite, 0, len(report.Tasks)),
+	}
+
+	for _, task := range report.Tasks {
+		className := fmt.Sprintf("watchful.task-%d", task.Index)
+		suite := JUnitTestSuite{
+			Name:      fmt.Sprintf("Task #%d: %s", task.Index, task.Command),
+			Time:      junitSeconds(task.End.Sub(task.Start)),
+			Timestamp: task.Start.UTC().Format("2006-01-02T15:04:05"),
+			TestCases: make([]JUnitTestCase, 0, len(task.Merkhets)),
+		}
+
+		if len(task.Merkhets) < 1 && len(task.Error) > 0 { // The task failed before any merkhet verdict was computed
+			suite.TestCases = append(suite.TestCases, JUnitTestCase{
+				Name:      "task",
+				ClassName: className,
+				Time:      suite.Time,
+				Error:     &JUnitFailure{Message: task.Error, Type: "task"},
+			})
+			suite.Errors++
+		}
+
+		for _, result := range task.Merkhets {
+			if len(result.Targets) < 1 {
+				suite.TestCases = append(suite.TestCases, junitTestCase(result.Name, className, suite.Time, result))
+				continue
+			}
+
+			for _, targetResult := range result.Targets { // Every target of the merkhet is reported separately
+				suite.TestCases = append(suite.TestCases, junitTestCase(fmt.Sprintf("%s %s", result.Name, targetResult.Name),
+					className, suite.Time, targetResult))
+			}
+		}
+
+		for _, testCase := range suite.TestCases {
+			if testCase.Failure != nil {
+				suite.Failures++
+			}
+		}
+
+		suite.Tests = len(suite.TestCases)
+		suites.Tests += suite.Tests
+		suites.Failures += suite.Failures
+		suites.Errors += suite.Errors
+		suites.Suites = append(suites.Suites, suite)
+	}
+
+	return suites
+}
+
+// WriteToFile writes the JUnit test suites as xml to the given file
+func (j *JUnitTestSuites) WriteToFile(file string) error {
+	content, err := xml.MarshalIndent(j, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, append([]byte(xml.Header), content...), 0644)
+}
+
+// junitTestCase creates the test case of a single merkhet result
+func junitTestCase(name string, className string, seconds string, result MerkhetResult) JUnitTestCase {
+	testCase := JUnitTestCase{
+		Name:      name,
+		ClassName: className,
+		Time:      seconds,
+	}
+
+	if !result.Valid {
+		testCase.Failure = &JUnitFailure{
+			Message: fmt.Sprintf("%s failed its threshold %s with (%d/%d) failed runs",
+				name, result.Threshold, result.FailedRuns, result.TotalRuns),
+			Type:    "threshold",
+			Content: junitFailureContent(result),
+		}
+	}
+	return testCase
+}
+
+// junitFailureContent lists the downtime and the failed runs of a merkhet result
+func junitFailureContent(result MerkhetResult) string {
+	lines := []string{
+		fmt.Sprintf("Threshold: %s", result.Threshold),
+		fmt.Sprintf("Failed runs: (%d/%d)", result.FailedRuns, result.TotalRuns),
+		fmt.Sprintf("Downtime: %s, longest outage %s", result.Downtime.Total, result.Downtime.Longest),
+	}
+
+	for _, failure := range result.Failures {
+		lines = append(lines, fmt.Sprintf("%s (%s): %s", failure.Start.Format(time.RFC3339Nano), failure.Duration, failure.Error))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// junitSeconds formats the duration as seconds as expected by JUnit
+func junitSeconds(d time.Duration) string {
+	if d < 0 {
+		d = 0
+	}
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
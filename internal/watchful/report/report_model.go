@@ -0,0 +1,136 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package report
+
+import (
+	"time"
+)
+
+// Report is the machine-readable summary of a complete watchful run
+type Report struct {
+	Configuration ConfigurationSummary `json:"configuration" yaml:"configuration"`
+	Start         time.Time            `json:"start" yaml:"start"`
+	End           time.Time            `json:"end" yaml:"end"`
+	Valid         bool                 `json:"valid" yaml:"valid"`
+	Error         string               `json:"error,omitempty" yaml:"error,omitempty"`
+	Tasks         []Task               `json:"tasks" yaml:"tasks"`
+}
+
+// ConfigurationSummary contains the parts of the watchful configuration relevant to interpret a report
+type ConfigurationSummary struct {
+	APIEndPoint string           `json:"api-endpoint" yaml:"api-endpoint"`
+	Domain      string           `json:"domain" yaml:"domain"`
+	Merkhets    []MerkhetSummary `json:"merkhets" yaml:"merkhets"`
+}
+
+// MerkhetSummary contains the configuration of a single merkhet
+type MerkhetSummary struct {
+	Name             string `json:"name" yaml:"name"`
+	Type             string `json:"type" yaml:"type"`
+	Threshold        string `json:"threshold" yaml:"threshold"`
+	LatencyThreshold string `json:"latency-threshold,omitempty" yaml:"latency-threshold,omitempty"`
+	Heartbeat        string `json:"heartbeat,omitempty" yaml:"heartbeat,omitempty"`
+}
+
+// Task contains the outcome of a single task and the merkhet verdicts computed after it
+type Task struct {
+	Index            int             `json:"index" yaml:"index"`
+	Command          string          `json:"cmd" yaml:"cmd"`
+	Arguments        []string        `json:"args,omitempty" yaml:"args,omitempty"`
+	MerkhetWhitelist []string        `json:"merkhet-whitelist,omitempty" yaml:"merkhet-whitelist,omitempty"`
+	MerkhetBlacklist []string        `json:"merkhet-blacklist,omitempty" yaml:"merkhet-blacklist,omitempty"`
+	Start            time.Time       `json:"start" yaml:"start"`
+	End              time.Time       `json:"end" yaml:"end"`
+	Error            string          `json:"error,omitempty" yaml:"error,omitempty"`
+	Merkhets         []MerkhetResult `json:"merkhets" yaml:"merkhets"`
+}
+
+// MerkhetResult contains the verdict of a merkhet at the end of a task.
+// The run counts and validity cover every run up to the end of the task, matching the verdict watchful uses,
+// while the downtime and the failures only cover the runs recorded during the task
+type MerkhetResult struct {
+	Name           string          `json:"name" yaml:"name"`
+	Threshold      string          `json:"threshold" yaml:"threshold"`
+	TotalRuns      int             `json:"total-runs" yaml:"total-runs"`
+	SuccessfulRuns int             `json:"successful-runs" yaml:"successful-runs"`
+	FailedRuns     int             `json:"failed-runs" yaml:"failed-runs"`
+	SkippedRuns    int             `json:"skipped-runs,omitempty" yaml:"skipped-runs,omitempty"`
+	Valid          bool            `json:"valid" yaml:"valid"`
+	Downtime       Downtime        `json:"downtime" yaml:"downtime"`
+	Latency        *Latency        `json:"latency,omitempty" yaml:"latency,omitempty"`
+	Instances      map[int]int     `json:"instances,omitempty" yaml:"instances,omitempty"`
+	LogLoss        *LogLoss        `json:"log-loss,omitempty" yaml:"log-loss,omitempty"`
+	Interruptions  int             `json:"interruptions,omitempty" yaml:"interruptions,omitempty"`
+	Disconnects    []Disconnect    `json:"disconnects,omitempty" yaml:"disconnects,omitempty"`
+	Failures       []Failure       `json:"failures,omitempty" yaml:"failures,omitempty"`
+	Targets        []MerkhetResult `json:"targets,omitempty" yaml:"targets,omitempty"`
+}
+
+// Downtime contains the outages a merkhet detected during a task
+type Downtime struct {
+	Total   string   `json:"total" yaml:"total"`
+	Longest string   `json:"longest" yaml:"longest"`
+	Outages []Outage `json:"outages,omitempty" yaml:"outages,omitempty"`
+}
+
+// Latency contains the percentiles of the latencies of the runs a merkhet recorded during a task
+type Latency struct {
+	P50 string `json:"p50" yaml:"p50"`
+	P95 string `json:"p95" yaml:"p95"`
+	P99 string `json:"p99" yaml:"p99"`
+}
+
+// Disconnect is an unexpected drop of a long-lived connection during a task. The reconnect time is empty if the
+// connection was not reestablished within the task
+type Disconnect struct {
+	Connection    int       `json:"connection" yaml:"connection"`
+	Time          time.Time `json:"time" yaml:"time"`
+	Reason        string    `json:"reason" yaml:"reason"`
+	ReconnectTime string    `json:"reconnect-time,omitempty" yaml:"reconnect-time,omitempty"`
+}
+
+// LogLoss contains the amount of numbered log lines a merkhet expected and received during a task
+type LogLoss struct {
+	Expected   int    `json:"expected" yaml:"expected"`
+	Received   int    `json:"received" yaml:"received"`
+	Lost       int    `json:"lost" yaml:"lost"`
+	OutOfOrder int    `json:"out-of-order" yaml:"out-of-order"`
+	Truncated  int    `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+	Rate       string `json:"rate" yaml:"rate"`
+}
+
+// Outage is a single contiguous window of failed runs
+type Outage struct {
+	Start       time.Time `json:"start" yaml:"start"`
+	End         time.Time `json:"end" yaml:"end"`
+	LastFailure time.Time `json:"last-failure" yaml:"last-failure"`
+	Duration    string    `json:"duration" yaml:"duration"`
+	Failures    int       `json:"failures" yaml:"failures"`
+	Target      string    `json:"target,omitempty" yaml:"target,omitempty"`
+}
+
+// Failure is a single failed run of a merkhet
+type Failure struct {
+	Start    time.Time `json:"start" yaml:"start"`
+	Duration string    `json:"duration" yaml:"duration"`
+	Error    string    `json:"error" yaml:"error"`
+	Target   string    `json:"target,omitempty" yaml:"target,omitempty"`
+}
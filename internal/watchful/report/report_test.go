@@ -0,0 +1,245 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package report_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"gopkg.in/yaml.v2"
+
+	"github.com/homeport/watchful/internal/watchful/cfg"
+	"github.com/homeport/watchful/internal/watchful/report"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+var _ = Describe("Report testing", func() {
+	Context("Tests the recording and writing of a run report", func() {
+
+		var (
+			config    *cfg.WatchfulConfig
+			recorder  *report.Recorder
+			base      *merkhet.SimpleBase
+			directory string
+		)
+
+		BeforeEach(func() {
+			config = &cfg.WatchfulConfig{
+				CloudFoundryConfig: cfg.CloudFoundryConfig{
+					APIEndPoint: "https://api.domain.com",
+					Domain:      "https://domain.com",
+				},
+				TaskConfigurations: []cfg.TaskConfiguration{
+					{Executable: "/bin/bash", Parameters: []string{"-c", "true"}},
+				},
+				MerkhetConfigurations: []cfg.MerkhetConfiguration{
+					{Name: "http-availability", Threshold: "1"},
+				},
+			}
+			recorder = report.NewRecorder(config)
+			base = merkhet.NewSimpleBase(nil, merkhet.NewFlatConfiguration("http-availability", 1))
+
+			var err error
+			directory, err = ioutil.TempDir("", "watchful-report")
+			Expect(err).To(BeNil())
+		})
+
+		AfterEach(func() {
+			Expect(os.RemoveAll(directory)).To(BeNil())
+		})
+
+		It("should record the tasks and merkhet verdicts", func() {
+			start := time.Now()
+
+			recorder.StartTask(1, config.TaskConfigurations[0])
+			base.StartTask(1)
+			base.Record(merkhet.NewSample(start, time.Second, nil))
+			base.Record(merkhet.NewSample(start.Add(time.Second), time.Second, fmt.Errorf("connection refused")))
+			base.Record(merkhet.NewSample(start.Add(3*time.Second), time.Second, nil))
+			recorder.RecordMerkhet(1, base.Configuration(), base.NewResultSet())
+			recorder.FinishTask(1, nil)
+
+			result := recorder.Finish(nil)
+			Expect(result.Valid).To(BeTrue())
+			Expect(result.Configuration.Merkhets).To(HaveLen(1))
+			Expect(result.Configuration.Merkhets[0].Type).To(BeEquivalentTo("http-availability"))
+			Expect(result.Tasks).To(HaveLen(1))
+
+			task := result.Tasks[0]
+			Expect(task.Command).To(BeEquivalentTo("/bin/bash"))
+			Expect(task.End.Before(task.Start)).To(BeFalse())
+			Expect(task.Merkhets).To(HaveLen(1))
+
+			merkhetResult := task.Merkhets[0]
+			Expect(merkhetResult.TotalRuns).To(BeEquivalentTo(3))
+			Expect(merkhetResult.FailedRuns).To(BeEquivalentTo(1))
+			Expect(merkhetResult.Valid).To(BeTrue())
+			Expect(merkhetResult.Downtime.Total).To(BeEquivalentTo("2s"))
+			Expect(merkhetResult.Failures).To(HaveLen(1))
+			Expect(merkhetResult.Failures[0].Error).To(BeEquivalentTo("connection refused"))
+			Expect(merkhetResult.Latency).To(BeEquivalentTo(&report.Latency{P50: "1s", P95: "1s", P99: "1s"}))
+		})
+
+		It("should report every target of a merkhet separately", func() {
+			recorder.StartTask(1, config.TaskConfigurations[0])
+			base.StartTask(1)
+			base.Record(merkhet.NewTargetSample("api", time.Now(), time.Second, nil))
+			base.Record(merkhet.NewTargetSample("login", time.Now(), time.Second, fmt.Errorf("status 502")))
+			base.Record(merkhet.NewTargetSample("login", time.Now(), time.Second, fmt.Errorf("status 502")))
+			recorder.RecordMerkhet(1, base.Configuration(), base.NewResultSet())
+			recorder.FinishTask(1, nil)
+
+			result := recorder.Finish(nil)
+			merkhetResult := result.Tasks[0].Merkhets[0]
+			Expect(merkhetResult.Valid).To(BeFalse())
+			Expect(merkhetResult.Targets).To(HaveLen(2))
+			Expect(merkhetResult.Targets[0].Name).To(BeEquivalentTo("api"))
+			Expect(merkhetResult.Targets[0].Valid).To(BeTrue())
+			Expect(merkhetResult.Targets[1].FailedRuns).To(BeEquivalentTo(2))
+			Expect(merkhetResult.Targets[1].Failures[0].Target).To(BeEquivalentTo("login"))
+
+			suites := report.NewJUnitTestSuites(result)
+			Expect(suites.Tests).To(BeEquivalentTo(2))
+			Expect(suites.Failures).To(BeEquivalentTo(1))
+			Expect(suites.Suites[0].TestCases[1].Name).To(BeEquivalentTo("http-availability login"))
+		})
+
+		It("should report the log loss of a merkhet", func() {
+			recorder.StartTask(1, config.TaskConfigurations[0])
+			base.StartTask(1)
+			sample := merkhet.NewSample(time.Now(), time.Second, nil)
+			sample.LogLoss = &merkhet.LogLoss{Expected: 8, Received: 7, Lost: 1, OutOfOrder: 2}
+			sample.Interruptions = 1
+			base.Record(sample)
+			recorder.RecordMerkhet(1, base.Configuration(), base.NewResultSet())
+			recorder.FinishTask(1, nil)
+
+			result := recorder.Finish(nil)
+			Expect(result.Tasks[0].Merkhets[0].LogLoss).To(BeEquivalentTo(&report.LogLoss{
+				Expected:   8,
+				Received:   7,
+				Lost:       1,
+				OutOfOrder: 2,
+				Rate:       "12.50%",
+			}))
+			Expect(result.Tasks[0].Merkhets[0].Interruptions).To(BeEquivalentTo(1))
+		})
+
+		It("should report the disconnects of a merkhet", func() {
+			recorder.StartTask(1, config.TaskConfigurations[0])
+			base.StartTask(1)
+			start := time.Now()
+			sample := merkhet.NewSample(start, time.Second, fmt.Errorf("dropped"))
+			sample.Disconnects = []merkhet.Disconnect{
+				{Connection: 0, Time: start, Reason: "EOF", Reconnected: start.Add(1500 * time.Millisecond)},
+				{Connection: 1, Time: start, Reason: "EOF"},
+			}
+			base.Record(sample)
+			recorder.RecordMerkhet(1, base.Configuration(), base.NewResultSet())
+			recorder.FinishTask(1, nil)
+
+			result := recorder.Finish(nil)
+			Expect(result.Tasks[0].Merkhets[0].Disconnects).To(BeEquivalentTo([]report.Disconnect{
+				{Connection: 0, Time: start, Reason: "EOF", ReconnectTime: "1.5s"},
+				{Connection: 1, Time: start, Reason: "EOF"},
+			}))
+		})
+
+		It("should record the error of a failed run", func() {
+			recorder.StartTask(1, config.TaskConfigurations[0])
+			recorder.FinishTask(1, fmt.Errorf("exit status 1"))
+
+			result := recorder.Finish(fmt.Errorf("failure in task #1"))
+			Expect(result.Valid).To(BeFalse())
+			Expect(result.Error).To(BeEquivalentTo("failure in task #1"))
+			Expect(result.Tasks[0].Error).To(BeEquivalentTo("exit status 1"))
+		})
+
+		It("should write the report as json", func() {
+			file := filepath.Join(directory, "report.json")
+			Expect(recorder.Finish(nil).WriteToFile(file)).To(BeNil())
+
+			content, err := ioutil.ReadFile(file)
+			Expect(err).To(BeNil())
+
+			parsed := report.Report{}
+			Expect(json.Unmarshal(content, &parsed)).To(BeNil())
+			Expect(parsed.Configuration.APIEndPoint).To(BeEquivalentTo("https://api.domain.com"))
+		})
+
+		It("should convert the report into JUnit test suites", func() {
+			recorder.StartTask(1, config.TaskConfigurations[0])
+			base.StartTask(1)
+			base.Record(merkhet.NewSample(time.Now(), time.Second, fmt.Errorf("connection refused")))
+			base.Record(merkhet.NewSample(time.Now(), time.Second, fmt.Errorf("connection refused")))
+			recorder.RecordMerkhet(1, base.Configuration(), base.NewResultSet())
+			recorder.FinishTask(1, nil)
+
+			recorder.StartTask(2, config.TaskConfigurations[0])
+			recorder.FinishTask(2, fmt.Errorf("exit status 1"))
+
+			suites := report.NewJUnitTestSuites(recorder.Finish(nil))
+			Expect(suites.Suites).To(HaveLen(2))
+			Expect(suites.Tests).To(BeEquivalentTo(2))
+			Expect(suites.Failures).To(BeEquivalentTo(1))
+			Expect(suites.Errors).To(BeEquivalentTo(1))
+
+			testCase := suites.Suites[0].TestCases[0]
+			Expect(testCase.Name).To(BeEquivalentTo("http-availability"))
+			Expect(testCase.Failure).ToNot(BeNil())
+			Expect(testCase.Failure.Message).To(ContainSubstring("(2/2) failed runs"))
+			Expect(testCase.Failure.Message).To(ContainSubstring("threshold 1"))
+			Expect(testCase.Failure.Content).To(ContainSubstring("connection refused"))
+
+			Expect(suites.Suites[1].TestCases[0].Error.Message).To(BeEquivalentTo("exit status 1"))
+
+			file := filepath.Join(directory, "junit.xml")
+			Expect(suites.WriteToFile(file)).To(BeNil())
+
+			content, err := ioutil.ReadFile(file)
+			Expect(err).To(BeNil())
+
+			parsed := report.JUnitTestSuites{}
+			Expect(xml.Unmarshal(content, &parsed)).To(BeNil())
+			Expect(parsed.Suites[0].TestCases[0].Failure).ToNot(BeNil())
+		})
+
+		It("should write the report as yaml", func() {
+			file := filepath.Join(directory, "report.yml")
+			Expect(recorder.Finish(nil).WriteToFile(file)).To(BeNil())
+
+			content, err := ioutil.ReadFile(file)
+			Expect(err).To(BeNil())
+
+			parsed := report.Report{}
+			Expect(yaml.Unmarshal(content, &parsed)).To(BeNil())
+			Expect(parsed.Configuration.Domain).To(BeEquivalentTo("https://domain.com"))
+			Expect(parsed.Valid).To(BeTrue())
+		})
+	})
+})
@@ -22,13 +22,18 @@ package services
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/gonvenience/bunt"
-	"github.com/homeport/watchful/internal/watchful/cfg"
 	"github.com/homeport/watchful/internal/watchful/merkhets"
+	"github.com/homeport/watchful/internal/watchful/metrics"
+	"github.com/homeport/watchful/internal/watchful/report"
 	"github.com/homeport/watchful/pkg/cfw"
 	"github.com/homeport/watchful/pkg/logger"
 	"github.com/homeport/watchful/pkg/merkhet"
@@ -58,19 +63,25 @@ type MainService struct {
 	ConfigContent           string
 	PushedAppSampleLanguage string
 	Verbose                 bool
+	ReportFile              string
+	JUnitFile               string
+	MetricsAddress          string
 }
 
 // Execute executes watchful with all outside parameters
 func (e *MainService) Execute() error {
-	config := &cfg.WatchfulConfig{}
-	if len(e.ConfigContent) > 0 { // Load config
-		if err := cfg.ParseFromString(e.ConfigContent, config); err != nil {
-			return errors.Wrap(err, "could not parse cli provided config")
-		}
-	} else {
-		if err := cfg.ParseFromFile("config.yml", config); err != nil {
-			return errors.Wrap(err, "could not parse file based config")
+	configContent, configSource := []byte(e.ConfigContent), "cli provided"
+	if len(configContent) < 1 { // Load config
+		content, err := ioutil.ReadFile("config.yml")
+		if err != nil {
+			return errors.Wrap(err, "could not read file based config")
 		}
+		configContent, configSource = content, "file based"
+	}
+
+	config, err := LoadConfiguration(configContent)
+	if err != nil {
+		return errors.Wrap(err, fmt.Sprintf("could not load %s config", configSource))
 	}
 
 	loggerChannelProvider := logger.NewChannelProvider(10)                   // Create logger channel provider
@@ -109,6 +120,8 @@ func (e *MainService) Execute() error {
 	cloudFoundryCLI := cfw.NewBashCloudFoundryCLI()
 	worker := cfw.NewCloudFoundryWorker(cloudFoundryLogger, cloudFoundryCLI)
 
+	reportRecorder := report.NewRecorder(config)
+
 	appProvider := merkhets.NewMutexSingleAppProvider(cloudFoundryCLI, "watchful", assetService.SampleAppPath())
 	merkhetCore := NewMerkhetService(config, loggerFactory, loggerConfig.GroupByLogger(watchfulLogger),
 		appProvider, cloudFoundryCLI) // Create merkhet core
@@ -116,6 +129,19 @@ func (e *MainService) Execute() error {
 		return err
 	}
 
+	metricsExporter := metrics.NewExporter(merkhetCore.Pool)
+	if len(e.MetricsAddress) > 0 {
+		metricsServer := &http.Server{Addr: e.MetricsAddress, Handler: metricsExporter.Handler()}
+		defer metricsServer.Close()
+
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				watchfulLogger.WriteString(logger.Error, bunt.Sprintf("Red{Could not serve metrics on %s:} %s", e.MetricsAddress, err.Error()))
+			}
+		}()
+		watchfulLogger.WriteString(logger.Info, bunt.Sprintf("Serving metrics on %s/metrics", e.MetricsAddress))
+	}
+
 	go func() {
 		watchfulLogger.WriteString(logger.Info, bunt.Sprintf("Aqua{Using CloudFoundryCLI version:⤳}"))
 		cloudFoundryCLI.Version().SubscribeOnOut(watchfulLogger.ReportingOn(logger.Info)).Sync()
@@ -144,6 +170,9 @@ func (e *MainService) Execute() error {
 		taskIndex := 0
 		for currentTaskConfig := taskWorker.Next(); currentTaskConfig != nil; currentTaskConfig = taskWorker.Next() { // Loop over all tasks
 			taskIndex++
+			merkhetCore.StartTask(taskIndex)
+			metricsExporter.SetTaskIndex(taskIndex)
+			reportRecorder.StartTask(taskIndex, *currentTaskConfig)
 
 			if len(currentTaskConfig.MerkhetWhitelist) > 0 {
 				merkhetCore.ApplyWhitelist(currentTaskConfig.MerkhetWhitelist)
@@ -161,14 +190,35 @@ func (e *MainService) Execute() error {
 			}
 
 			if err := taskWorker.Execute(); err != nil {
+				reportRecorder.FinishTask(taskIndex, err)
 				taskLogger.WriteString(logger.Error, err.Error())
 				watchfulLogger.WriteString(logger.Error, bunt.Sprintf("Red{Task #%d failed}", taskIndex))
 				shutdownNotifier <- &ErrorSignal{InnerError: errors.Wrap(err, fmt.Sprintf("Faliure in task #%d", taskIndex))} // Shutdown with the given error
 				return
 			}
 
-			if err := merkhetCore.Pool.ForEach(merkhet.ConsumeSync(func(m merkhet.Merkhet, future merkhet.Future) { // Check merkhet result
+			err := merkhetCore.Pool.ForEach(merkhet.ConsumeSync(func(m merkhet.Merkhet, future merkhet.Future) { // Check merkhet result
 				result := m.Base().NewResultSet()
+				reportRecorder.RecordMerkhet(taskIndex, m.Base().Configuration(), result)
+				taskSamples := merkhet.SamplesOfTask(result.Samples(), taskIndex)
+				reportDowntime(m.Base().Logger(), merkhet.NewDowntime(taskSamples), location)
+				reportLatency(m.Base().Logger(), taskSamples)
+				reportInstances(m.Base().Logger(), taskSamples)
+				reportSkippedRuns(m.Base().Logger(), taskSamples)
+				reportLogLoss(m.Base().Logger(), taskSamples)
+				reportDisconnects(m.Base().Logger(), taskSamples, location)
+
+				for _, target := range result.Targets() {
+					targetResult := result.ForTarget(target)
+					if targetResult.Valid() {
+						m.Base().Logger().WriteString(logger.Info, bunt.Sprintf("Gray{ - } %s : Green{passed} with (%d/%d) successful runs",
+							target, targetResult.SuccessfulRuns(), targetResult.TotalRuns()))
+					} else {
+						m.Base().Logger().WriteString(logger.Info, bunt.Sprintf("Gray{ - } %s : Red{failed} with (%d/%d) failed runs",
+							target, targetResult.FailedRuns(), targetResult.TotalRuns()))
+					}
+				}
+
 				if !result.Valid() {
 					m.Base().Logger().WriteString(logger.Info, bunt.Sprintf("Red{Tests failed} with (%d/%d) failed runs",
 						result.FailedRuns(), result.TotalRuns()))
@@ -180,7 +230,10 @@ func (e *MainService) Execute() error {
 						result.SuccessfulRuns(), result.TotalRuns()))
 					future.Complete(nil)
 				}
-			})).Wait().FirstError(); err != nil {
+			})).Wait().FirstError()
+			reportRecorder.FinishTask(taskIndex, err)
+
+			if err != nil {
 				watchfulLogger.WriteString(logger.Error, "A merkhet result was not valid!")
 				shutdownNotifier <- &ErrorSignal{InnerError: errors.Wrap(err, fmt.Sprintf("Faliure in merkhet for task #%d", taskIndex))} // Shutdown with the given error
 				return
@@ -197,6 +250,31 @@ func (e *MainService) Execute() error {
 	merkhetCore.Pool.Shutdown()
 	watchfulLogger.WriteString(logger.Info, bunt.Sprintf("DarkGreen{Shutdown merkhets}")) // stop merkhets
 
+	var result error
+	switch signalType := output.(type) {
+	case *ErrorSignal:
+		result = signalType.Error()
+	default:
+		result = fmt.Errorf("received external system signal: %s", signalType.String())
+	}
+
+	runReport := reportRecorder.Finish(result)
+	if len(e.ReportFile) > 0 {
+		if err := runReport.WriteToFile(e.ReportFile); err != nil {
+			watchfulLogger.WriteString(logger.Error, bunt.Sprintf("Red{Could not write report to %s:} %s", e.ReportFile, err.Error()))
+		} else {
+			watchfulLogger.WriteString(logger.Info, bunt.Sprintf("DarkGreen{Wrote report to %s}", e.ReportFile))
+		}
+	}
+
+	if len(e.JUnitFile) > 0 {
+		if err := report.NewJUnitTestSuites(runReport).WriteToFile(e.JUnitFile); err != nil {
+			watchfulLogger.WriteString(logger.Error, bunt.Sprintf("Red{Could not write JUnit report to %s:} %s", e.JUnitFile, err.Error()))
+		} else {
+			watchfulLogger.WriteString(logger.Info, bunt.Sprintf("DarkGreen{Wrote JUnit report to %s}", e.JUnitFile))
+		}
+	}
+
 	NewTeardownService(watchfulLogger, worker).Execute() // Teardown cf env
 	assetService.Cleanup()                               // Cleans the asset service
 
@@ -204,11 +282,99 @@ func (e *MainService) Execute() error {
 	loggerChannelProvider.Close()
 	loggerCluster.WaitGroup().Wait()
 
-	switch signalType := output.(type) {
-	case *ErrorSignal:
-		return signalType.Error()
-	default:
-		return fmt.Errorf("received external system signal: %s", signalType.String())
+	return result
+}
+
+// reportLatency writes the percentiles of the latencies of the samples to the logger
+func reportLatency(l logger.Logger, samples []merkhet.Sample) {
+	if len(samples) < 1 {
+		return
+	}
+
+	latency := merkhet.NewLatency(samples)
+	l.WriteString(logger.Info, bunt.Sprintf("Gray{Latency} p50 %s, p95 %s, p99 %s", latency.P50, latency.P95, latency.P99))
+}
+
+// reportInstances writes the amount of runs served by each instance of the sample app to the logger
+func reportInstances(l logger.Logger, samples []merkhet.Sample) {
+	instances := merkhet.InstanceDistribution(samples)
+	if len(instances) < 1 {
+		return
+	}
+
+	indices := make([]int, 0, len(instances))
+	for index := range instances {
+		indices = append(indices, index)
+	}
+	sort.Ints(indices)
+
+	distribution := make([]string, len(indices))
+	for i, index := range indices {
+		distribution[i] = fmt.Sprintf("#%d: %d", index, instances[index])
+	}
+	l.WriteString(logger.Info, bunt.Sprintf("Gray{Instances} %s", strings.Join(distribution, ", ")))
+}
+
+// reportSkippedRuns writes the amount of runs that were skipped as no worker was free to execute them to the logger
+func reportSkippedRuns(l logger.Logger, samples []merkhet.Sample) {
+	if skipped := merkhet.CountSkipped(samples); skipped > 0 {
+		l.WriteString(logger.Info, bunt.Sprintf("Gray{Skipped runs} %d, no worker was free to execute them", skipped))
+	}
+}
+
+// reportLogLoss writes the amount of numbered log lines that were lost or out of order to the logger
+func reportLogLoss(l logger.Logger, samples []merkhet.Sample) {
+	loss := merkhet.TotalLogLoss(samples)
+	if loss == nil {
+		return
+	}
+
+	l.WriteString(logger.Info, bunt.Sprintf("Gray{Log loss} %.2f%% (%d/%d lines lost), %d out of order",
+		loss.Rate(), loss.Lost, loss.Expected, loss.OutOfOrder))
+
+	if loss.Truncated > 0 {
+		l.WriteString(logger.Info, bunt.Sprintf("Gray{Not evaluated} %d lines, they were no longer part of the recent logs", loss.Truncated))
+	}
+
+	if interruptions := merkhet.TotalInterruptions(samples); interruptions > 0 {
+		l.WriteString(logger.Info, bunt.Sprintf("Red{Log stream was interrupted %d times}", interruptions))
+	}
+}
+
+// reportDisconnects writes the dropped long-lived connections and the time until they were reestablished to the logger
+func reportDisconnects(l logger.Logger, samples []merkhet.Sample, location *time.Location) {
+	for _, disconnect := range merkhet.DisconnectsOf(samples) {
+		if disconnect.Reconnected.IsZero() {
+			l.WriteString(logger.Info, bunt.Sprintf("Gray{ - } connection #%d dropped at %s, Red{not reestablished}: %s",
+				disconnect.Connection, disconnect.Time.In(location).Format(time.StampMilli), disconnect.Reason))
+			continue
+		}
+
+		l.WriteString(logger.Info, bunt.Sprintf("Gray{ - } connection #%d dropped at %s, reestablished after %s: %s",
+			disconnect.Connection, disconnect.Time.In(location).Format(time.StampMilli), disconnect.ReconnectTime(), disconnect.Reason))
+	}
+}
+
+// reportDowntime writes the outage windows of the downtime to the logger
+func reportDowntime(l logger.Logger, downtime merkhet.Downtime, location *time.Location) {
+	if len(downtime.Outages) < 1 {
+		return
+	}
+
+	l.WriteString(logger.Info, bunt.Sprintf("Red{Downtime of %s} across %d outages, longest outage %s",
+		downtime.Total(), len(downtime.Outages), downtime.Longest()))
+	l.WriteString(logger.Info, bunt.Sprintf("Gray{First failure at} %s Gray{and last failure at} %s",
+		downtime.FirstFailure().In(location).Format(time.StampMilli), downtime.LastFailure().In(location).Format(time.StampMilli)))
+
+	for _, outage := range downtime.Outages {
+		target := ""
+		if len(outage.Target) > 0 {
+			target = " of " + outage.Target
+		}
+
+		l.WriteString(logger.Debug, bunt.Sprintf("Gray{ - } %s Gray{to} %s : Red{%s} with %d failed runs%s",
+			outage.Start.In(location).Format(time.StampMilli), outage.End.In(location).Format(time.StampMilli),
+			outage.Duration(), outage.Failures, target))
 	}
 }
 
@@ -21,12 +21,12 @@
 package services
 
 import (
+	"fmt"
 	"regexp"
 	"strconv"
 	"time"
 
 	"github.com/homeport/watchful/internal/watchful/cfg"
-	"github.com/homeport/watchful/internal/watchful/merkhets"
 	"github.com/homeport/watchful/pkg/cfw"
 	"github.com/homeport/watchful/pkg/logger"
 	"github.com/homeport/watchful/pkg/merkhet"
@@ -35,6 +35,9 @@ import (
 var (
 	// PercentageThresholdRegex defines the regex that identifies a percentage value
 	PercentageThresholdRegex = regexp.MustCompile("([0-9]*\\.)?[0-9]*%")
+
+	// LatencyThresholdRegex defines the regex that identifies a latency threshold, eg: p99 < 500ms
+	LatencyThresholdRegex = regexp.MustCompile(`^\s*p([0-9]+(\.[0-9]+)?)\s*<\s*(\S+)\s*$`)
 )
 
 // MerkhetService defines the services responsible for controlling the merkhets
@@ -43,12 +46,13 @@ type MerkhetService struct {
 	Pool          merkhet.Pool
 	LoggerFactory logger.Factory
 	LoggerGroup   logger.Group
-	AppProvider   merkhets.AppProvider
+	AppProvider   merkhet.AppProvider
 	Cli           cfw.CloudFoundryCLI
+	Registry      merkhet.Registry
 }
 
 // NewMerkhetService creates a new merkhet services service
-func NewMerkhetService(configuration *cfg.WatchfulConfig, loggerFactory logger.Factory, loggerGroup logger.Group, appProvider merkhets.AppProvider, cli cfw.CloudFoundryCLI) *MerkhetService {
+func NewMerkhetService(configuration *cfg.WatchfulConfig, loggerFactory logger.Factory, loggerGroup logger.Group, appProvider merkhet.AppProvider, cli cfw.CloudFoundryCLI) *MerkhetService {
 	return &MerkhetService{
 		Configuration: configuration,
 		Pool:          merkhet.NewPool(),
@@ -56,6 +60,7 @@ func NewMerkhetService(configuration *cfg.WatchfulConfig, loggerFactory logger.F
 		LoggerFactory: loggerFactory,
 		AppProvider:   appProvider,
 		Cli:           cli,
+		Registry:      merkhet.DefaultRegistry,
 	}
 }
 
@@ -67,21 +72,33 @@ func (e *MerkhetService) Execute() error {
 			return err
 		}
 
-		switch c.Name {
-		case "http-availability":
-			e.Pool.StartWorker(merkhets.NewDefaultCurlMerkhet(e.Configuration.CloudFoundryConfig.Domain, base,
-				e.AppProvider),
-				c.GetHeartbeatRate(time.Second), e.defaultHeartbeatHandler())
-		case "app-pushability":
-			e.Pool.StartWorker(merkhets.NewPushMerkhet(base, e.AppProvider,
-				cfw.NewBashCloudFoundryCLI()), c.GetHeartbeatRate(time.Minute), e.defaultHeartbeatHandler())
-		case "cf-recent-log-functionality":
-			e.Pool.StartWorker(merkhets.NewLogRecentMerkhet(e.Cli, e.AppProvider, base),
-				c.GetHeartbeatRate(10*time.Second), e.defaultHeartbeatHandler())
-		case "cf-log-functionality":
-			e.Pool.StartWorker(merkhets.NewLogStreamMerkhet(e.Cli, e.AppProvider, base),
-				c.GetHeartbeatRate(30*time.Second), e.defaultHeartbeatHandler())
+		definition, ok := e.Registry.Lookup(c.GetType())
+		if !ok {
+			return fmt.Errorf("unknown merkhet type %s of merkhet %s", c.GetType(), c.Name)
 		}
+
+		heartbeat := c.GetHeartbeatRate(definition.DefaultHeartbeat)
+		m, err := definition.Factory(merkhet.FactoryContext{
+			Base:      base,
+			Settings:  c.Settings,
+			Heartbeat: heartbeat,
+			Dependencies: merkhet.Dependencies{
+				CLI:               e.Cli,
+				AppProvider:       e.AppProvider,
+				Domain:            e.Configuration.CloudFoundryConfig.Domain,
+				APIEndpoint:       e.Configuration.CloudFoundryConfig.APIEndPoint,
+				SkipSSLValidation: e.Configuration.CloudFoundryConfig.SkipSSLValidation,
+				Credentials: cfw.CloudFoundryCertificate{
+					Username: e.Configuration.CloudFoundryConfig.Username,
+					Password: e.Configuration.CloudFoundryConfig.Password,
+				},
+			},
+		})
+		if err != nil {
+			return fmt.Errorf("could not create merkhet %s: %s", c.Name, err.Error())
+		}
+
+		e.Pool.StartWorker(m, heartbeat, e.defaultHeartbeatHandler())
 	}
 
 	return nil
@@ -115,37 +132,122 @@ func (e *MerkhetService) ApplyBlacklist(blacklisted []string) {
 
 // createMerkhetBase creates a new merkhet base instance
 func (e *MerkhetService) createMerkhetBase(configuration cfg.MerkhetConfiguration) (base merkhet.Base, err error) {
+	merkhetConfig, err := parseMerkhetConfiguration(configuration)
+	if err != nil {
+		return nil, err
+	}
+
 	merkhetLogger := e.LoggerFactory.NewChanneledLogger(configuration.Name)
 	e.LoggerGroup.Add(merkhetLogger)
 
-	var merkhetConfig merkhet.Configuration
+	return merkhet.NewSimpleBase(merkhetLogger, merkhetConfig), nil
+}
+
+// parseMerkhetConfiguration creates the merkhet configuration matching the threshold of the given configuration,
+// extended by the latency threshold if one is configured
+func parseMerkhetConfiguration(configuration cfg.MerkhetConfiguration) (merkhet.Configuration, error) {
+	merkhetConfig, err := parseThresholdConfiguration(configuration)
+	if err != nil || len(configuration.LatencyThreshold) < 1 {
+		return merkhetConfig, err
+	}
+
+	percentile, maxLatency, err := parseLatencyThreshold(configuration.LatencyThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return merkhet.NewLatencyConfiguration(merkhetConfig, percentile, maxLatency), nil
+}
+
+// parseLatencyThreshold parses the percentile and the maximum latency of a latency threshold, eg: p99 < 500ms
+func parseLatencyThreshold(threshold string) (float64, time.Duration, error) {
+	match := LatencyThresholdRegex.FindStringSubmatch(threshold)
+	if match == nil {
+		return 0, 0, fmt.Errorf("latency-threshold %q is not of the form p<percentile> < <duration>, eg: p99 < 500ms", threshold)
+	}
+
+	percentile, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	if percentile <= 0 || percentile > 100 {
+		return 0, 0, fmt.Errorf("percentile %s of latency-threshold %q has to be within 0 and 100", match[1], threshold)
+	}
+
+	maxLatency, err := time.ParseDuration(match[3])
+	if err != nil {
+		return 0, 0, fmt.Errorf("latency %s of latency-threshold %q is not a duration", match[3], threshold)
+	}
+
+	if maxLatency <= 0 {
+		return 0, 0, fmt.Errorf("latency %s of latency-threshold %q has to be a positive duration", match[3], threshold)
+	}
+	return percentile, maxLatency, nil
+}
+
+// parseThresholdConfiguration creates the merkhet configuration matching the threshold of the given configuration.
+// The threshold is either a percentage, a flat amount of failed runs or the maximum duration of a single outage
+func parseThresholdConfiguration(configuration cfg.MerkhetConfiguration) (merkhet.Configuration, error) {
 	if PercentageThresholdRegex.Match([]byte(configuration.Threshold)) {
+		if configuration.MaxTotalDowntime != nil {
+			return nil, fmt.Errorf("max-total-downtime of merkhet %s requires a duration threshold", configuration.Name)
+		}
+
 		pureString := configuration.Threshold[:len(configuration.Threshold)-1]
-		if f, err := strconv.ParseFloat(pureString, 64); err == nil {
-			merkhetConfig = merkhet.NewPercentageConfiguration(configuration.Name, f/float64(100))
-		} else {
+		f, err := strconv.ParseFloat(pureString, 64)
+		if err != nil {
 			return nil, err
 		}
-	} else {
-		if i, err := strconv.ParseInt(configuration.Threshold, 0, 32); err == nil {
-			merkhetConfig = merkhet.NewFlatConfiguration(configuration.Name, int(i))
-		} else {
-			return nil, err
+		return merkhet.NewPercentageConfiguration(configuration.Name, f/float64(100)), nil
+	}
+
+	if i, err := strconv.ParseInt(configuration.Threshold, 0, 32); err == nil {
+		if configuration.MaxTotalDowntime != nil {
+			return nil, fmt.Errorf("max-total-downtime of merkhet %s requires a duration threshold", configuration.Name)
 		}
+		return merkhet.NewFlatConfiguration(configuration.Name, int(i)), nil
 	}
 
-	return merkhet.NewSimpleBase(merkhetLogger, merkhetConfig), nil
+	d, err := time.ParseDuration(configuration.Threshold)
+	if err != nil {
+		return nil, fmt.Errorf("threshold %s of merkhet %s is neither a percentage, a flat amount nor a duration",
+			configuration.Threshold, configuration.Name)
+	}
+
+	var maxTotalDowntime time.Duration
+	if configuration.MaxTotalDowntime != nil {
+		maxTotalDowntime = *configuration.MaxTotalDowntime
+	}
+	return merkhet.NewDurationConfiguration(configuration.Name, d, maxTotalDowntime), nil
+}
+
+// StartTask marks the beginning of the task with the given index on every merkhet
+func (e *MerkhetService) StartTask(index int) {
+	e.Pool.ForEach(merkhet.ConsumeSync(func(m merkhet.Merkhet, future merkhet.Future) {
+		m.Base().StartTask(index)
+		future.Complete(nil)
+	})).Wait()
 }
 
-// defaultHeartbeatHandler creates a new default consumer
+// defaultHeartbeatHandler creates a new default consumer. Merkhets measuring several targets record a sample per target
 func (e *MerkhetService) defaultHeartbeatHandler() merkhet.Consumer {
 	return merkhet.ConsumeAsync(func(m merkhet.Merkhet, future merkhet.Future) {
-		future.Complete(m.Execute())
-		if _, err := future.IsCompleted(); err != nil {
-			m.Base().RecordFailedRun()
-		} else {
-			m.Base().RecordSuccessfulRun()
+		if sampler, ok := m.(merkhet.Sampler); ok {
+			var err error
+			for _, sample := range sampler.ExecuteSamples() {
+				m.Base().Record(sample)
+				if sample.Failed() && err == nil {
+					err = fmt.Errorf("%s: %s", sample.Target, sample.Error)
+				}
+			}
+			future.Complete(err)
+			return
 		}
+
+		start := time.Now()
+		err := m.Execute()
+		m.Base().Record(merkhet.NewSample(start, time.Since(start), err))
+		future.Complete(err)
 	}).Notify(e.Pool.TaskWaitGroup())
 }
 
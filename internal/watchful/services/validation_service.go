@@ -0,0 +1,194 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/homeport/watchful/internal/watchful/cfg"
+	"github.com/homeport/watchful/pkg/merkhet"
+)
+
+// LoadConfiguration parses the yaml or json configuration content and validates it
+func LoadConfiguration(content []byte) (*cfg.WatchfulConfig, error) {
+	config := &cfg.WatchfulConfig{}
+	if err := cfg.ParseFromString(string(content), config); err != nil {
+		return nil, err
+	}
+
+	locator, err := cfg.NewLocator(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := NewValidationService(config, locator).Execute(); err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// ValidationProblem is a single problem found in the configuration
+type ValidationProblem struct {
+	Line    int
+	Path    string
+	Message string
+}
+
+// String returns the problem including its location in the configuration
+func (p ValidationProblem) String() string {
+	if p.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", p.Line, p.Path, p.Message)
+	}
+	return fmt.Sprintf("%s: %s", p.Path, p.Message)
+}
+
+// ValidationError is the error returned if the configuration contains problems
+type ValidationError struct {
+	Problems []ValidationProblem
+}
+
+// Error returns all problems found in the configuration
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Problems)+1)
+	lines = append(lines, fmt.Sprintf("found %d problems in the configuration:", len(e.Problems)))
+	for _, problem := range e.Problems {
+		lines = append(lines, "  "+problem.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ValidationService validates a parsed configuration and reports all problems at once
+type ValidationService struct {
+	Configuration *cfg.WatchfulConfig
+	Locator       *cfg.Locator
+	Registry      merkhet.Registry
+	problems      []ValidationProblem
+}
+
+// NewValidationService creates a new validation service. The locator may be nil if line numbers are not needed
+func NewValidationService(configuration *cfg.WatchfulConfig, locator *cfg.Locator) *ValidationService {
+	return &ValidationService{Configuration: configuration, Locator: locator, Registry: merkhet.DefaultRegistry}
+}
+
+// Execute validates the configuration and returns a ValidationError listing every problem found
+func (e *ValidationService) Execute() error {
+	e.problems = make([]ValidationProblem, 0)
+
+	names := make(map[string]bool)
+	for i, c := range e.Configuration.MerkhetConfigurations {
+		if len(c.Name) < 1 {
+			e.report("merkhet has no name", "merkhets", i)
+		}
+
+		definition, ok := e.Registry.Lookup(c.GetType())
+		if !ok {
+			typeKey := "type"
+			if len(c.Type) < 1 {
+				typeKey = "name"
+			}
+			e.report(fmt.Sprintf("unknown merkhet type %q, known types are %s", c.GetType(),
+				strings.Join(e.Registry.Names(), ", ")), "merkhets", i, typeKey)
+		} else if _, err := definition.Factory(merkhet.FactoryContext{Settings: c.Settings,
+			Heartbeat: c.GetHeartbeatRate(definition.DefaultHeartbeat)}); err != nil {
+			e.report(fmt.Sprintf("invalid settings: %s", err.Error()), "merkhets", i, "settings")
+		}
+
+		if names[c.Name] {
+			e.report(fmt.Sprintf("merkhet name %q is used more than once, use distinct names and the type to run the same merkhet twice",
+				c.Name), "merkhets", i, "name")
+		}
+		names[c.Name] = true
+
+		if _, err := parseThresholdConfiguration(c); err != nil {
+			e.report(err.Error(), "merkhets", i, "threshold")
+		}
+
+		if len(c.LatencyThreshold) > 0 {
+			if _, _, err := parseLatencyThreshold(c.LatencyThreshold); err != nil {
+				e.report(err.Error(), "merkhets", i, "latency-threshold")
+			}
+		}
+
+		if c.HeartbeatRate != nil && *c.HeartbeatRate <= 0 {
+			e.report(fmt.Sprintf("heartbeat %s has to be a positive duration", c.HeartbeatRate.String()), "merkhets", i, "heartbeat")
+		}
+	}
+
+	for i, task := range e.Configuration.TaskConfigurations {
+		if len(task.Executable) < 1 {
+			e.report("task has no cmd to execute", "tasks", i)
+		}
+
+		for j, name := range task.MerkhetWhitelist {
+			if !names[name] {
+				e.report(fmt.Sprintf("whitelisted merkhet %q is not configured", name), "tasks", i, "merkhet-whitelist", j)
+			}
+		}
+
+		for j, name := range task.MerkhetBlacklist {
+			if !names[name] {
+				e.report(fmt.Sprintf("blacklisted merkhet %q is not configured", name), "tasks", i, "merkhet-blacklist", j)
+			}
+		}
+
+		if len(task.MerkhetWhitelist) > 0 && len(task.MerkhetBlacklist) > 0 {
+			e.report("merkhet-blacklist is ignored as a merkhet-whitelist is configured", "tasks", i, "merkhet-blacklist")
+		}
+	}
+
+	if _, err := time.LoadLocation(e.Configuration.LoggerConfiguration.TimeLocation); err != nil {
+		e.report(fmt.Sprintf("unknown time location %q", e.Configuration.LoggerConfiguration.TimeLocation),
+			"logger-config", "time-location")
+	}
+
+	if len(e.problems) > 0 {
+		return &ValidationError{Problems: e.problems}
+	}
+	return nil
+}
+
+// report adds a new problem found under the given path
+func (e *ValidationService) report(message string, path ...interface{}) {
+	e.problems = append(e.problems, ValidationProblem{
+		Line:    e.Locator.Line(path...),
+		Path:    renderPath(path),
+		Message: message,
+	})
+}
+
+// renderPath renders the path to a node, eg: merkhets[1].name
+func renderPath(path []interface{}) string {
+	rendered := ""
+	for _, element := range path {
+		switch key := element.(type) {
+		case int:
+			rendered += fmt.Sprintf("[%d]", key)
+		default:
+			if len(rendered) > 0 {
+				rendered += "."
+			}
+			rendered += fmt.Sprintf("%v", key)
+		}
+	}
+	return rendered
+}
@@ -0,0 +1,63 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cfw
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+var (
+	// AppInstancesRegex defines the regex that retrieves the running and desired instances from the output of cf app
+	AppInstancesRegex = regexp.MustCompile(`(?m)^instances:\s+([0-9]+)/([0-9]+)`)
+)
+
+// AppInstances contains the amount of running and desired instances of an app
+type AppInstances struct {
+	Running int
+	Desired int
+}
+
+// Settled returns if exactly the given amount of instances is desired and running
+func (a AppInstances) Settled(instances int) bool {
+	return a.Running == instances && a.Desired == instances
+}
+
+// ParseAppInstances parses the instances line of the output of the cf app command, eg: instances: 1/2
+func ParseAppInstances(output string) (AppInstances, error) {
+	match := AppInstancesRegex.FindStringSubmatch(output)
+	if match == nil {
+		return AppInstances{}, fmt.Errorf("the app output contains no instances")
+	}
+
+	running, err := strconv.Atoi(match[1])
+	if err != nil {
+		return AppInstances{}, err
+	}
+
+	desired, err := strconv.Atoi(match[2])
+	if err != nil {
+		return AppInstances{}, err
+	}
+
+	return AppInstances{Running: running, Desired: desired}, nil
+}
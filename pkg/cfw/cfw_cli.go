@@ -49,8 +49,40 @@ import (
 //
 // Scale will scale the app instance to the provided amount
 //
+// Restart stops and starts the app again
+//
+// Restage recreates the droplet of the app and restarts it
+//
+// SetEnv sets the environment variable of the app, which takes effect after the next restart
+//
+// MapTCPRoute maps a route of the tcp domain on the given port to the app
+//
+// App shows the health and status of the app, which can be parsed with ParseAppInstances
+//
+// AppGUID prints the guid of the app
+//
+// RunTask runs the command as a task of the app under the given task name, the id can be parsed with ParseTaskID
+//
+// Tasks lists the tasks of the app, which can be parsed with ParseTasks
+//
 // RecentLogs returns a command promise that returns the recent logs of the app. This w
 //
+// CreateUserProvidedService creates a user provided service that drains the logs of bound apps to the syslog drain url
+//
+// CreateService creates a service instance of the plan of the service offered in the marketplace
+//
+// BindService binds the service instance to the app
+//
+// UnbindService unbinds the service instance from the app
+//
+// DeleteService deletes the service instance
+//
+// CreateServiceBroker registers a service broker that is only visible in the targeted space
+//
+// DeleteServiceBroker deletes the service broker
+//
+// Curl executes an authenticated GET request against the given path of the cloud controller api
+//
 // Version executes the version command
 type CloudFoundryCLI interface {
 	API(apiEndpoint string, validateSSL bool) CommandPromise
@@ -63,8 +95,24 @@ type CloudFoundryCLI interface {
 	Push(path string, name string, instances int) CommandPromise
 	Delete(name string) CommandPromise
 	Scale(name string, instances int) CommandPromise
+	Restart(name string) CommandPromise
+	Restage(name string) CommandPromise
+	SetEnv(name string, key string, value string) CommandPromise
+	MapTCPRoute(name string, domain string, port int) CommandPromise
+	App(name string) CommandPromise
+	AppGUID(name string) CommandPromise
+	RunTask(app string, command string, name string) CommandPromise
+	Tasks(app string) CommandPromise
 	RecentLogs(name string) CommandPromise
 	StreamLogs(name string) CommandPromise
+	CreateUserProvidedService(name string, syslogDrainURL string) CommandPromise
+	CreateService(service string, plan string, name string) CommandPromise
+	BindService(app string, service string) CommandPromise
+	UnbindService(app string, service string) CommandPromise
+	DeleteService(name string) CommandPromise
+	CreateServiceBroker(name string, username string, password string, url string) CommandPromise
+	DeleteServiceBroker(name string) CommandPromise
+	Curl(path string) CommandPromise
 	Version() CommandPromise
 }
 
@@ -128,16 +176,96 @@ func (b *BashCloudFoundryCLI) Scale(name string, instances int) CommandPromise {
 	return createCFCommandPromise(fmt.Sprintf("scale %s -i %d", name, instances))
 }
 
+// Restart stops and starts the app again
+func (b *BashCloudFoundryCLI) Restart(name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("restart %s", name))
+}
+
+// Restage recreates the droplet of the app and restarts it
+func (b *BashCloudFoundryCLI) Restage(name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("restage %s", name))
+}
+
+// SetEnv sets the environment variable of the app, which takes effect after the next restart
+func (b *BashCloudFoundryCLI) SetEnv(name string, key string, value string) CommandPromise {
+	return createCFArgumentsPromise("set-env", name, key, value)
+}
+
+// MapTCPRoute maps a route of the tcp domain on the given port to the app
+func (b *BashCloudFoundryCLI) MapTCPRoute(name string, domain string, port int) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("map-route %s %s --port %d", name, domain, port))
+}
+
+// App shows the health and status of the app, which can be parsed with ParseAppInstances
+func (b *BashCloudFoundryCLI) App(name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("app %s", name))
+}
+
+// AppGUID prints the guid of the app
+func (b *BashCloudFoundryCLI) AppGUID(name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("app %s --guid", name))
+}
+
+// RunTask runs the command as a task of the app under the given task name, the id can be parsed with ParseTaskID
+func (b *BashCloudFoundryCLI) RunTask(app string, command string, name string) CommandPromise {
+	return createCFArgumentsPromise("run-task", app, command, "--name", name)
+}
+
+// Tasks lists the tasks of the app, which can be parsed with ParseTasks
+func (b *BashCloudFoundryCLI) Tasks(app string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("tasks %s", app))
+}
+
 // RecentLogs returns a command promise that returns the recent logs of the app
 func (b *BashCloudFoundryCLI) RecentLogs(name string) CommandPromise {
 	return createCFCommandPromise(fmt.Sprintf("logs --recent %s", name))
 }
 
-// StreamLogs opens a stream of logs. Note that this command promise will need a timeout assigned
+// StreamLogs opens a stream of logs. The stream does not end on its own, it has to be killed or needs a timeout assigned
 func (b *BashCloudFoundryCLI) StreamLogs(name string) CommandPromise {
 	return createCFCommandPromise(fmt.Sprintf("logs %s", name))
 }
 
+// CreateUserProvidedService creates a user provided service that drains the logs of bound apps to the syslog drain url
+func (b *BashCloudFoundryCLI) CreateUserProvidedService(name string, syslogDrainURL string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("create-user-provided-service %s -l %s", name, syslogDrainURL))
+}
+
+// CreateService creates a service instance of the plan of the service offered in the marketplace
+func (b *BashCloudFoundryCLI) CreateService(service string, plan string, name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("create-service %s %s %s", service, plan, name))
+}
+
+// BindService binds the service instance to the app
+func (b *BashCloudFoundryCLI) BindService(app string, service string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("bind-service %s %s", app, service))
+}
+
+// UnbindService unbinds the service instance from the app
+func (b *BashCloudFoundryCLI) UnbindService(app string, service string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("unbind-service %s %s", app, service))
+}
+
+// DeleteService deletes the service instance
+func (b *BashCloudFoundryCLI) DeleteService(name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("delete-service %s -f", name))
+}
+
+// CreateServiceBroker registers a service broker that is only visible in the targeted space
+func (b *BashCloudFoundryCLI) CreateServiceBroker(name string, username string, password string, url string) CommandPromise {
+	return createCFArgumentsPromise("create-service-broker", name, username, password, url, "--space-scoped")
+}
+
+// DeleteServiceBroker deletes the service broker
+func (b *BashCloudFoundryCLI) DeleteServiceBroker(name string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("delete-service-broker %s -f", name))
+}
+
+// Curl executes an authenticated GET request against the given path of the cloud controller api
+func (b *BashCloudFoundryCLI) Curl(path string) CommandPromise {
+	return createCFCommandPromise(fmt.Sprintf("curl %s", path))
+}
+
 // Version executes the version command
 func (b *BashCloudFoundryCLI) Version() CommandPromise {
 	return createCFCommandPromise("version")
@@ -157,3 +285,9 @@ func NewBashCloudFoundryCLI() *BashCloudFoundryCLI {
 func createCFCommandPromise(parameter string) CommandPromise {
 	return NewSimpleCommandPromise(exec.Command("cf", SplitParameterString(parameter)...))
 }
+
+// createCFArgumentsPromise creates a new command promise executing cf with the arguments as they are, which allows
+// arguments containing spaces
+func createCFArgumentsPromise(arguments ...string) CommandPromise {
+	return NewSimpleCommandPromise(exec.Command("cf", arguments...))
+}
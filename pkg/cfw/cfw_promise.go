@@ -42,7 +42,10 @@ var (
 // SubscribeOnErr subscribes the provided writer to the error stream of the command promise
 // This will overwrite previous subscriber
 //
-// Timeout adds a timeout to the command promise. The default is -1, which represents no timeout
+// Timeout adds a timeout to the command promise. The default is -1, which represents no timeout.
+// The running command is killed once the timeout is reached
+//
+// Kill kills the running command and prevents the remaining commands of the promise from being started
 //
 // Sync executes the command in sync to the go routine it was called in, returning the result
 //
@@ -53,24 +56,28 @@ type CommandPromise interface {
 	SubscribeOnErr(writer io.Writer) CommandPromise
 	Environment(key string, value string) CommandPromise
 	Timeout(duration time.Duration) CommandPromise
+	Kill() error
 	Sync() error
 	Async(subscriber func(e error)) *sync.WaitGroup
 }
 
 // NewSimpleSlicedCommandPromise returns a simple command promise implementation containing multiple commands
 func NewSimpleSlicedCommandPromise(commands []*exec.Cmd) *SimpleCommandPromise {
-	return &SimpleCommandPromise{commands: commands}
+	return &SimpleCommandPromise{commands: commands, lock: &sync.Mutex{}}
 }
 
 // NewSimpleCommandPromise returns a simple command promise implementation containing one command
 func NewSimpleCommandPromise(command *exec.Cmd) *SimpleCommandPromise {
-	return &SimpleCommandPromise{commands: []*exec.Cmd{command}}
+	return &SimpleCommandPromise{commands: []*exec.Cmd{command}, lock: &sync.Mutex{}}
 }
 
 // SimpleCommandPromise is an implementation of the CommandPromise interface that is able to run multiple commands
 type SimpleCommandPromise struct {
 	commands     []*exec.Cmd
 	TimeoutValue time.Duration
+	lock         *sync.Mutex
+	running      *exec.Cmd
+	killed       bool
 }
 
 // SubscribeOnOut will subscribe the writer instance to the command promise
@@ -106,28 +113,71 @@ func (c *SimpleCommandPromise) Timeout(duration time.Duration) CommandPromise {
 	return c
 }
 
+// Kill kills the running command and prevents the remaining commands from being started
+func (c *SimpleCommandPromise) Kill() error {
+	defer c.lock.Unlock()
+
+	c.lock.Lock()
+	c.killed = true
+	if c.running == nil || c.running.Process == nil {
+		return nil
+	}
+	return c.running.Process.Kill()
+}
+
 // Sync executes the command promise and returns the result
 // The command will be executed on the same go routine
 func (c *SimpleCommandPromise) Sync() error {
-	done := make(chan error)
+	done := make(chan error, len(c.commands)+2)
 	if c.TimeoutValue > 0 { // Start timeout task
+		timer := time.NewTimer(c.TimeoutValue)
+		finished := make(chan struct{})
+		defer close(finished)
+
 		go func() {
-			<-time.NewTimer(c.TimeoutValue).C
-			done <- ErrorCommandPromiseTimeout
+			defer timer.Stop()
+
+			select {
+			case <-timer.C:
+				done <- ErrorCommandPromiseTimeout // Queued before the error of the killed command
+				_ = c.Kill()
+			case <-finished:
+			}
 		}()
 	}
 
 	go func() { // Start command tasks
-		for _, c := range c.commands {
-			e := c.Run()
-			if e != nil {
+		for _, command := range c.commands {
+			if e := c.run(command); e != nil {
 				done <- e
 			}
 		}
 		done <- nil
 	}()
 
-	return <-done // We don't have to close the channel, it will be collected by the gc once the timeout value is done too
+	return <-done // The channel is buffered, so the tasks finish even if nobody receives their result anymore
+}
+
+// run starts the command unless the promise was killed and waits for it to finish
+func (c *SimpleCommandPromise) run(command *exec.Cmd) error {
+	c.lock.Lock()
+	if c.killed {
+		c.lock.Unlock()
+		return nil
+	}
+
+	c.running = command
+	e := command.Start()
+	c.lock.Unlock()
+
+	if e == nil {
+		e = command.Wait()
+	}
+
+	c.lock.Lock()
+	c.running = nil
+	c.lock.Unlock()
+	return e
 }
 
 // Async executes the command promise and returns the result to the passed subscriber
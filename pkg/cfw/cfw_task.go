@@ -0,0 +1,90 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package cfw
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// TaskPending is the state of a task that was not started yet
+	TaskPending = "PENDING"
+
+	// TaskRunning is the state of a task that is running
+	TaskRunning = "RUNNING"
+
+	// TaskCanceling is the state of a task that is being canceled
+	TaskCanceling = "CANCELING"
+
+	// TaskSucceeded is the state of a task that exited successfully
+	TaskSucceeded = "SUCCEEDED"
+
+	// TaskFailed is the state of a task that failed or was canceled
+	TaskFailed = "FAILED"
+)
+
+var (
+	// TaskIDRegex defines the regex that retrieves the id of a task from the output of cf run-task
+	TaskIDRegex = regexp.MustCompile(`(?m)^task id:\s+([0-9]+)`)
+
+	// TaskRowRegex defines the regex that retrieves the id, name and state of a task from a row of the output of cf tasks.
+	// The name may contain spaces, so the row is anchored on the state column
+	TaskRowRegex = regexp.MustCompile(`(?m)^([0-9]+)[ \t]+(.+?)[ \t]+(PENDING|RUNNING|CANCELING|SUCCEEDED|FAILED)\b`)
+)
+
+// Task contains the id, name and state of a task of an app
+type Task struct {
+	ID    int
+	Name  string
+	State string
+}
+
+// Completed returns if the task reached one of its final states
+func (t Task) Completed() bool {
+	return t.State == TaskSucceeded || t.State == TaskFailed
+}
+
+// ParseTaskID parses the id of the task submitted by the cf run-task command, eg: task id: 3
+func ParseTaskID(output string) (int, error) {
+	match := TaskIDRegex.FindStringSubmatch(output)
+	if match == nil {
+		return 0, fmt.Errorf("the run-task output contains no task id")
+	}
+
+	return strconv.Atoi(match[1])
+}
+
+// ParseTasks parses the task table of the output of the cf tasks command
+func ParseTasks(output string) ([]Task, error) {
+	tasks := make([]Task, 0)
+	for _, match := range TaskRowRegex.FindAllStringSubmatch(output, -1) {
+		id, err := strconv.Atoi(match[1])
+		if err != nil {
+			return nil, err
+		}
+
+		tasks = append(tasks, Task{ID: id, Name: match[2], State: match[3]})
+	}
+
+	return tasks, nil
+}
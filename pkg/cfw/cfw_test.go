@@ -22,6 +22,8 @@ package cfw_test
 
 import (
 	"fmt"
+	"os/exec"
+	"time"
 
 	. "github.com/onsi/ginkgo"
 	. "github.com/onsi/gomega"
@@ -37,5 +39,91 @@ var _ = Describe("Testing cloud foundry worker instance", func() {
 
 			Expect(cfw.SplitParameterString(p)).To(BeEquivalentTo(expected))
 		})
+
+		It("should parse the instances of an app", func() {
+			output := `Showing health and status for app watchful in org watchful / space watchful as admin...
+
+name:              watchful
+requested state:   started
+routes:            watchful.domain.com
+
+type:           web
+instances:      1/2
+memory usage:   1G
+     state      since                  cpu    memory      disk      details
+#0   running    2019-01-01T00:00:00Z   0.0%   10M of 1G   5M of 1G
+#1   starting   2019-01-01T00:00:10Z   0.0%   0 of 1G     0 of 1G
+`
+
+			instances, err := cfw.ParseAppInstances(output)
+			Expect(err).To(BeNil())
+			Expect(instances).To(BeEquivalentTo(cfw.AppInstances{Running: 1, Desired: 2}))
+			Expect(instances.Settled(2)).To(BeFalse())
+			Expect(cfw.AppInstances{Running: 2, Desired: 2}.Settled(2)).To(BeTrue())
+
+			_, err = cfw.ParseAppInstances("App watchful not found")
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("should parse the id of a submitted task", func() {
+			output := `Creating task for app watchful in org watchful / space watchful as admin...
+OK
+
+Task has been submitted successfully for execution.
+task name:   watchful-task
+task id:     3
+`
+
+			id, err := cfw.ParseTaskID(output)
+			Expect(err).To(BeNil())
+			Expect(id).To(BeEquivalentTo(3))
+
+			_, err = cfw.ParseTaskID("App watchful not found")
+			Expect(err).ToNot(BeNil())
+		})
+
+		It("should parse the tasks of an app", func() {
+			output := `Getting tasks for app watchful in org watchful / space watchful as admin...
+OK
+
+id   name            state       start time                      command
+4    nightly backup  PENDING     Tue, 01 Jan 2019 00:00:15 UTC   ./backup.sh
+3    watchful-task   RUNNING     Tue, 01 Jan 2019 00:00:10 UTC   sleep 1
+2    watchful-task   SUCCEEDED   Tue, 01 Jan 2019 00:00:05 UTC   sleep 1
+1    watchful-task   FAILED      Tue, 01 Jan 2019 00:00:00 UTC   exit 1
+`
+
+			tasks, err := cfw.ParseTasks(output)
+			Expect(err).To(BeNil())
+			Expect(tasks).To(BeEquivalentTo([]cfw.Task{
+				{ID: 4, Name: "nightly backup", State: cfw.TaskPending},
+				{ID: 3, Name: "watchful-task", State: cfw.TaskRunning},
+				{ID: 2, Name: "watchful-task", State: cfw.TaskSucceeded},
+				{ID: 1, Name: "watchful-task", State: cfw.TaskFailed},
+			}))
+			Expect(tasks[0].Completed()).To(BeFalse())
+			Expect(tasks[1].Completed()).To(BeFalse())
+			Expect(tasks[2].Completed()).To(BeTrue())
+		})
+
+		It("should kill the command once the timeout is reached", func() {
+			start := time.Now()
+			promise := cfw.NewSimpleSlicedCommandPromise([]*exec.Cmd{exec.Command("sleep", "10"), exec.Command("false")})
+			Expect(promise.Timeout(100 * time.Millisecond).Sync()).To(Equal(cfw.ErrorCommandPromiseTimeout))
+			Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		})
+
+		It("should kill a running command", func() {
+			promise := cfw.NewSimpleCommandPromise(exec.Command("sleep", "10"))
+			result := make(chan error, 1)
+			promise.Async(func(e error) {
+				result <- e
+			})
+
+			time.Sleep(100 * time.Millisecond)
+			Expect(promise.Kill()).To(BeNil())
+			Eventually(result, 5*time.Second).Should(Receive())
+			Expect(promise.Kill()).To(BeNil())
+		})
 	})
 })
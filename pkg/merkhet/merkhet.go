@@ -23,6 +23,7 @@ package merkhet
 import (
 	"fmt"
 	"strconv"
+	"time"
 )
 
 // Merkhet defines a runnable measurement task that can be executed during the Cloud Foundry maintenance
@@ -49,17 +50,24 @@ type Merkhet interface {
 	Base() Base
 }
 
+// Sampler is an optional interface of merkhets that measure several targets on a single execution
+//
+// ExecuteSamples executes one single test and returns a sample for every measured target
+type Sampler interface {
+	ExecuteSamples() []Sample
+}
+
 // Configuration contains the passed configuration values for a Merkhet instance
 //
 // Name returns the name provided in the configuration.
 //
-// ValidRun returns whether the provided total relative to the fails is still considered a viable run
+// ValidRun returns whether the provided samples are still considered a viable run
 // The behaviour of this method is heavily reliant on the implementation
 //
 // ThresholdAsString returns the threshold as a string
 type Configuration interface {
 	Name() string
-	ValidRun(totalRuns int, failedRuns int) bool
+	ValidRun(samples []Sample) bool
 	ThresholdAsString() string
 }
 
@@ -85,8 +93,8 @@ func (p *PercentageConfiguration) Name() string {
 }
 
 // ValidRun returns if the failed runs compared to the total runs are below the provided percentage threshold
-func (p *PercentageConfiguration) ValidRun(totalRuns int, failedRuns int) bool {
-	return (float64(failedRuns) / float64(totalRuns)) <= p.percentageThreshold
+func (p *PercentageConfiguration) ValidRun(samples []Sample) bool {
+	return (float64(CountFailures(samples)) / float64(CountRuns(samples))) <= p.percentageThreshold
 }
 
 // ThresholdAsString returns the threshold as a string
@@ -107,8 +115,8 @@ func (f *FlatConfiguration) Name() string {
 }
 
 // ValidRun returns if the failed runs compared to the total runs are below the provided percentage threshold
-func (f *FlatConfiguration) ValidRun(totalRuns int, failedRuns int) bool {
-	return failedRuns <= f.flatThreshold
+func (f *FlatConfiguration) ValidRun(samples []Sample) bool {
+	return CountFailures(samples) <= f.flatThreshold
 }
 
 // ThresholdAsString returns the threshold as a string
@@ -116,6 +124,37 @@ func (f *FlatConfiguration) ThresholdAsString() string {
 	return strconv.Itoa(f.flatThreshold)
 }
 
+// DurationConfiguration is an implementation of the Configuration interface that is based on the duration of
+// the outages detected in the samples
+type DurationConfiguration struct {
+	namedConfiguration *namedConfiguration
+	maxOutage          time.Duration
+	maxTotalDowntime   time.Duration
+}
+
+// Name returns the name stored in the configuration delegate
+func (d *DurationConfiguration) Name() string {
+	return d.namedConfiguration.Name()
+}
+
+// ValidRun returns if no single outage took longer than the maximum outage duration and,
+// if configured, the summed up downtime is below the maximum total downtime
+func (d *DurationConfiguration) ValidRun(samples []Sample) bool {
+	downtime := NewDowntime(samples)
+	if downtime.Longest() > d.maxOutage {
+		return false
+	}
+	return d.maxTotalDowntime <= 0 || downtime.Total() <= d.maxTotalDowntime
+}
+
+// ThresholdAsString returns the threshold as a string
+func (d *DurationConfiguration) ThresholdAsString() string {
+	if d.maxTotalDowntime <= 0 {
+		return fmt.Sprintf("%s per outage", d.maxOutage)
+	}
+	return fmt.Sprintf("%s per outage, %s in total", d.maxOutage, d.maxTotalDowntime)
+}
+
 // NewPercentageConfiguration creates a new configuration instance that uses a percentage threshold
 func NewPercentageConfiguration(name string, percentageThreshold float64) *PercentageConfiguration {
 	return &PercentageConfiguration{
@@ -135,3 +174,15 @@ func NewFlatConfiguration(name string, flatThreshold int) *FlatConfiguration {
 		flatThreshold: flatThreshold,
 	}
 }
+
+// NewDurationConfiguration creates a new configuration instance that uses the outage durations as threshold.
+// A maximum total downtime of zero or less disables the check of the summed up downtime
+func NewDurationConfiguration(name string, maxOutage time.Duration, maxTotalDowntime time.Duration) *DurationConfiguration {
+	return &DurationConfiguration{
+		namedConfiguration: &namedConfiguration{
+			name: name,
+		},
+		maxOutage:        maxOutage,
+		maxTotalDowntime: maxTotalDowntime,
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+var (
+	// LatencyBuckets defines the upper bounds of the latency histogram of aggregated samples.
+	// Latencies above the last bound are counted in an additional bucket
+	LatencyBuckets = []time.Duration{
+		time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+		10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+		100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+		time.Second, 2 * time.Second, 5 * time.Second,
+		10 * time.Second, 20 * time.Second, 30 * time.Second, time.Minute,
+	}
+)
+
+// Aggregate summarizes many runs in a single sample, eg: the requests the load mode of the http merkhet sends during
+// one execution. Instead of keeping every run, it only keeps their amount, their failures and a latency histogram.
+// Several outages within the aggregated runs are merged into one, which lasts from the first failure until the
+// runs recovered from the last one. Every bucket of the histogram keeps the lowest and highest latency counted in it,
+// so percentiles can be interpolated within the latencies actually measured. Skipped runs were never executed, eg: as
+// the load mode had no free worker, so they are neither counted as runs nor as failures
+type Aggregate struct {
+	Runs         int             `json:"runs" yaml:"runs"`
+	Failures     int             `json:"failures" yaml:"failures"`
+	Skipped      int             `json:"skipped" yaml:"skipped"`
+	FirstFailure time.Time       `json:"first-failure" yaml:"first-failure"`
+	LastFailure  time.Time       `json:"last-failure" yaml:"last-failure"`
+	Recovery     time.Time       `json:"recovery" yaml:"recovery"`
+	Latencies    []int           `json:"latencies" yaml:"latencies"`
+	MinLatencies []time.Duration `json:"min-latencies" yaml:"min-latencies"`
+	MaxLatencies []time.Duration `json:"max-latencies" yaml:"max-latencies"`
+	MaxLatency   time.Duration   `json:"max-latency" yaml:"max-latency"`
+	Instances    map[int]int     `json:"instances,omitempty" yaml:"instances,omitempty"`
+}
+
+// NewAggregateSample aggregates the samples of single runs into one sample that started at the given time and took
+// the given duration. The sample is considered failed if any of the runs failed, its error is the first error
+func NewAggregateSample(target string, start time.Time, duration time.Duration, runs []Sample) Sample {
+	sorted := make([]Sample, len(runs))
+	copy(sorted, runs)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Start.Before(sorted[j].Start) })
+
+	aggregate := &Aggregate{
+		Latencies:    make([]int, len(LatencyBuckets)+1),
+		MinLatencies: make([]time.Duration, len(LatencyBuckets)+1),
+		MaxLatencies: make([]time.Duration, len(LatencyBuckets)+1),
+	}
+	sample := NewTargetSample(target, start, duration, nil)
+	sample.Aggregate = aggregate
+
+	for _, run := range sorted {
+		aggregate.Runs++
+		aggregate.count(run.Latency())
+		if run.Latency() > aggregate.MaxLatency {
+			aggregate.MaxLatency = run.Latency()
+		}
+
+		if run.Instance != nil {
+			if aggregate.Instances == nil {
+				aggregate.Instances = make(map[int]int)
+			}
+			aggregate.Instances[*run.Instance]++
+		}
+
+		switch {
+		case run.Failed():
+			if aggregate.Failures == 0 {
+				aggregate.FirstFailure = run.Start
+				sample.Error = run.Error
+			}
+			aggregate.Failures++
+			aggregate.Recovery = time.Time{}
+			if run.End().After(aggregate.LastFailure) {
+				aggregate.LastFailure = run.End()
+			}
+
+		case aggregate.Failures > 0 && aggregate.Recovery.IsZero():
+			aggregate.Recovery = run.Start
+		}
+	}
+
+	if aggregate.Failures > 0 {
+		sample.Error = fmt.Sprintf("%d of %d runs failed, first error: %s", aggregate.Failures, aggregate.Runs, sample.Error)
+	}
+	return sample
+}
+
+// failureStart returns the point in time the first failed run of the sample started
+func (s Sample) failureStart() time.Time {
+	if s.Aggregate != nil && !s.Aggregate.FirstFailure.IsZero() {
+		return s.Aggregate.FirstFailure
+	}
+	return s.Start
+}
+
+// failureEnd returns the point in time the last failed run of the sample ended
+func (s Sample) failureEnd() time.Time {
+	if s.Aggregate != nil && !s.Aggregate.LastFailure.IsZero() {
+		return s.Aggregate.LastFailure
+	}
+	return s.End()
+}
+
+// count counts the latency in its bucket of the histogram
+func (a *Aggregate) count(latency time.Duration) {
+	bucket := latencyBucket(latency)
+	if a.Latencies[bucket] == 0 || latency < a.MinLatencies[bucket] {
+		a.MinLatencies[bucket] = latency
+	}
+	if latency > a.MaxLatencies[bucket] {
+		a.MaxLatencies[bucket] = latency
+	}
+	a.Latencies[bucket]++
+}
+
+// latencyBucket returns the index of the histogram bucket the latency is counted in
+func latencyBucket(latency time.Duration) int {
+	for i, bound := range LatencyBuckets {
+		if latency <= bound {
+			return i
+		}
+	}
+	return len(LatencyBuckets)
+}
@@ -22,6 +22,7 @@ package merkhet
 
 import (
 	"sync"
+	"time"
 
 	"github.com/homeport/watchful/pkg/logger"
 )
@@ -32,6 +33,11 @@ import (
 //
 // Configuration returns the configuration instance the merkhet uses
 //
+// StartTask marks the beginning of the task with the given index. Every sample recorded
+// afterwards will be associated with this task
+//
+// Record records one new sample
+//
 // RecordSuccessfulRun records one new successful run
 //
 // RecordFailedRuns records one new failed run
@@ -40,18 +46,29 @@ import (
 type Base interface {
 	Logger() logger.Logger
 	Configuration() Configuration
+	StartTask(index int)
+	Record(sample Sample)
 	RecordSuccessfulRun()
 	RecordFailedRun()
 	NewResultSet() Result
 }
 
+// Observable is an optional interface of bases that notify observers about the samples they record
+//
+// Observe calls the observer with every sample recorded so far and with every sample recorded afterwards.
+// The observer is called while the base is locked, so it must not call the base itself
+type Observable interface {
+	Observe(observer func(sample Sample))
+}
+
 // SimpleBase is a basic variable driven implementation of the Base interface
 type SimpleBase struct {
 	LoggerReference        logger.Logger
 	ConfigurationReference Configuration
-	SuccessfulRuns         int
-	FailedRun              int
+	Samples                []Sample
+	TaskIndex              int
 	Lock                   *sync.Mutex
+	observers              []func(sample Sample)
 }
 
 // NewSimpleBase creates a new basic simple base
@@ -61,13 +78,20 @@ func NewSimpleBase(loggerReference logger.Logger, configurationReference Configu
 
 // NewSetSimpleBase creates a new simple base with setting failed and successful runs
 func NewSetSimpleBase(loggerReference logger.Logger, configurationReference Configuration, successfulRuns int, failedRun int) *SimpleBase {
-	return &SimpleBase{
+	base := &SimpleBase{
 		LoggerReference:        loggerReference,
 		ConfigurationReference: configurationReference,
-		SuccessfulRuns:         successfulRuns,
-		FailedRun:              failedRun,
+		Samples:                make([]Sample, 0, successfulRuns+failedRun),
 		Lock:                   &sync.Mutex{},
 	}
+
+	for i := 0; i < successfulRuns; i++ {
+		base.RecordSuccessfulRun()
+	}
+	for i := 0; i < failedRun; i++ {
+		base.RecordFailedRun()
+	}
+	return base
 }
 
 // Logger returns the logger reference
@@ -80,24 +104,53 @@ func (b *SimpleBase) Configuration() Configuration {
 	return b.ConfigurationReference
 }
 
-// RecordSuccessfulRun records a successful run
-func (b *SimpleBase) RecordSuccessfulRun() {
+// StartTask marks the beginning of the task with the given index
+func (b *SimpleBase) StartTask(index int) {
 	defer b.Lock.Unlock()
 
 	b.Lock.Lock()
-	b.SuccessfulRuns++
+	b.TaskIndex = index
 }
 
-// RecordFailedRun records a failed run
-func (b *SimpleBase) RecordFailedRun() {
+// Record records a sample and associates it with the currently running task
+func (b *SimpleBase) Record(sample Sample) {
+	defer b.Lock.Unlock()
+
+	b.Lock.Lock()
+	sample.TaskIndex = b.TaskIndex
+	b.Samples = append(b.Samples, sample)
+	for _, observer := range b.observers {
+		observer(sample)
+	}
+}
+
+// Observe calls the observer with every sample recorded so far and with every sample recorded afterwards
+func (b *SimpleBase) Observe(observer func(sample Sample)) {
 	defer b.Lock.Unlock()
 
 	b.Lock.Lock()
-	b.FailedRun++
+	for _, sample := range b.Samples {
+		observer(sample)
+	}
+	b.observers = append(b.observers, observer)
+}
+
+// RecordSuccessfulRun records a successful run without any duration
+func (b *SimpleBase) RecordSuccessfulRun() {
+	b.Record(NewSample(time.Now(), 0, nil))
+}
+
+// RecordFailedRun records a failed run without any duration
+func (b *SimpleBase) RecordFailedRun() {
+	b.Record(Sample{Start: time.Now(), Error: "failed run"})
 }
 
 // NewResultSet builds a new result set instance
 func (b *SimpleBase) NewResultSet() Result {
-	totalRuns := b.FailedRun + b.SuccessfulRuns
-	return NewMerkhetResult(b.SuccessfulRuns, b.FailedRun, b.Configuration().ValidRun(totalRuns, b.FailedRun))
+	defer b.Lock.Unlock()
+
+	b.Lock.Lock()
+	samples := make([]Sample, len(b.Samples))
+	copy(samples, b.Samples)
+	return NewEvaluatedResult(b.Configuration(), samples)
 }
@@ -0,0 +1,49 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"github.com/homeport/watchful/pkg/cfw"
+	"github.com/homeport/watchful/pkg/logger"
+)
+
+// AppProvider defines an object that provides one single pushed app in the cf
+//
+// Push pushes the app if not pushed yet
+//
+// ForcePush pushes the app under the given name, regardless of whether it was pushed before
+//
+// AppName returns the app name
+type AppProvider interface {
+	Push(l logger.Logger) (infoLog logger.CachedLogger, errorLog logger.CachedLogger, err error)
+	ForcePush(l logger.Logger, appName string) (infoLog logger.CachedLogger, errorLog logger.CachedLogger, err error)
+	AppName() string
+}
+
+// Dependencies contains the dependencies watchful shares between all merkhet instances
+type Dependencies struct {
+	CLI               cfw.CloudFoundryCLI
+	AppProvider       AppProvider
+	Domain            string
+	APIEndpoint       string
+	SkipSSLValidation bool
+	Credentials       cfw.CloudFoundryCertificate
+}
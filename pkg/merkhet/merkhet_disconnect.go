@@ -0,0 +1,72 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"sort"
+	"time"
+)
+
+// Disconnect is an unexpected drop of a long-lived connection. A merkhet records a disconnect in the sample of the
+// execution that detected it and again in the sample of the execution that detected the connection was reestablished
+type Disconnect struct {
+	Connection  int       `json:"connection" yaml:"connection"`
+	Time        time.Time `json:"time" yaml:"time"`
+	Reason      string    `json:"reason" yaml:"reason"`
+	Reconnected time.Time `json:"reconnected" yaml:"reconnected"`
+}
+
+// ReconnectTime returns the duration until the connection was established again, or zero if it was not yet
+func (d Disconnect) ReconnectTime() time.Duration {
+	if d.Reconnected.IsZero() {
+		return 0
+	}
+	return d.Reconnected.Sub(d.Time)
+}
+
+// DisconnectsOf returns the disconnects recorded in the samples ordered by their time. A disconnect recorded in
+// several samples is only returned once, including its reconnect if any of the samples recorded it
+func DisconnectsOf(samples []Sample) []Disconnect {
+	type key struct {
+		connection int
+		time       time.Time
+	}
+
+	indices := make(map[key]int)
+	disconnects := make([]Disconnect, 0)
+	for _, sample := range samples {
+		for _, disconnect := range sample.Disconnects {
+			k := key{connection: disconnect.Connection, time: disconnect.Time}
+			if i, ok := indices[k]; ok {
+				if !disconnect.Reconnected.IsZero() {
+					disconnects[i].Reconnected = disconnect.Reconnected
+				}
+				continue
+			}
+
+			indices[k] = len(disconnects)
+			disconnects = append(disconnects, disconnect)
+		}
+	}
+
+	sort.SliceStable(disconnects, func(i, j int) bool { return disconnects[i].Time.Before(disconnects[j].Time) })
+	return disconnects
+}
@@ -0,0 +1,148 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"sort"
+	"time"
+)
+
+// Outage is a contiguous window of failed samples. The end of the outage is the recovery, the last failure is the end
+// of the last failed run within the outage
+type Outage struct {
+	Start       time.Time `json:"start" yaml:"start"`
+	End         time.Time `json:"end" yaml:"end"`
+	LastFailure time.Time `json:"last-failure" yaml:"last-failure"`
+	Failures    int       `json:"failures" yaml:"failures"`
+	Target      string    `json:"target,omitempty" yaml:"target,omitempty"`
+}
+
+// Duration returns the duration of the outage
+func (o Outage) Duration() time.Duration {
+	return o.End.Sub(o.Start)
+}
+
+// Downtime contains the outage windows detected in a timeline of samples
+type Downtime struct {
+	Outages []Outage
+}
+
+// NewDowntime detects the outage windows in the given samples.
+// An outage starts with the first failed sample and ends with the start of the next successful sample,
+// or with the end of the last failed sample if the merkhet did not recover in the same task.
+// Aggregated samples start an outage with their first failed run and end it once their runs recovered.
+// Samples of different tasks never share an outage, as the merkhet may not have been running in between.
+// Samples of different targets are never part of the same outage either, the outages of all targets are ordered by their start
+func NewDowntime(samples []Sample) Downtime {
+	downtime := Downtime{Outages: detectOutages(SamplesOfTarget(samples, ""))}
+	for _, target := range TargetsOf(samples) {
+		downtime.Outages = append(downtime.Outages, detectOutages(SamplesOfTarget(samples, target))...)
+	}
+
+	sort.SliceStable(downtime.Outages, func(i, j int) bool {
+		return downtime.Outages[i].Start.Before(downtime.Outages[j].Start)
+	})
+	return downtime
+}
+
+// detectOutages detects the outage windows in the samples of a single target
+func detectOutages(samples []Sample) []Outage {
+	sorted := make([]Sample, len(samples))
+	copy(sorted, samples)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Start.Before(sorted[j].Start)
+	})
+
+	outages := make([]Outage, 0)
+
+	var current *Outage
+	closeOutage := func(end time.Time) {
+		current.End = end
+		outages = append(outages, *current)
+		current = nil
+	}
+
+	for i, sample := range sorted {
+		if current != nil && sorted[i-1].TaskIndex != sample.TaskIndex {
+			closeOutage(sorted[i-1].End())
+		}
+
+		switch {
+		case sample.Failed() && current == nil:
+			current = &Outage{Start: sample.failureStart(), LastFailure: sample.failureEnd(), Failures: sample.FailedRuns(), Target: sample.Target}
+		case sample.Failed():
+			current.Failures += sample.FailedRuns()
+			if sample.failureEnd().After(current.LastFailure) {
+				current.LastFailure = sample.failureEnd()
+			}
+		case current != nil:
+			closeOutage(sample.Start)
+		}
+
+		if current != nil && sample.Aggregate != nil && !sample.Aggregate.Recovery.IsZero() {
+			closeOutage(sample.Aggregate.Recovery)
+		}
+	}
+
+	if current != nil {
+		closeOutage(sorted[len(sorted)-1].End())
+	}
+	return outages
+}
+
+// FirstFailure returns the start of the first outage, or the zero time if there was none
+func (d Downtime) FirstFailure() time.Time {
+	if len(d.Outages) < 1 {
+		return time.Time{}
+	}
+	return d.Outages[0].Start
+}
+
+// LastFailure returns the end of the last failed run of all outages, or the zero time if there was none
+func (d Downtime) LastFailure() time.Time {
+	var last time.Time
+	for _, outage := range d.Outages {
+		if outage.LastFailure.After(last) {
+			last = outage.LastFailure
+		}
+	}
+	return last
+}
+
+// Total returns the summed up duration of all outages
+func (d Downtime) Total() time.Duration {
+	var total time.Duration
+	for _, outage := range d.Outages {
+		total += outage.Duration()
+	}
+	return total
+}
+
+// Longest returns the duration of the longest single outage
+func (d Downtime) Longest() time.Duration {
+	var longest time.Duration
+	for _, outage := range d.Outages {
+		if outage.Duration() > longest {
+			longest = outage.Duration()
+		}
+	}
+	return longest
+}
@@ -22,6 +22,7 @@ package merkhet
 
 import (
 	"os"
+	"sync"
 	"time"
 )
 
@@ -48,25 +49,31 @@ type TickedHeartbeat struct {
 	Consumer      Consumer
 	Interval      time.Duration
 	closure       chan os.Signal
+	lock          sync.Mutex
 }
 
 // StartBeating starts the heartbeats go routine
 func (t *TickedHeartbeat) StartBeating() {
+	defer t.lock.Unlock()
+
+	t.lock.Lock()
 	t.closure = make(chan os.Signal)
+	closure := t.closure
 
 	if t.Consumer == nil {
 		return
 	}
 
 	t.Ticker = time.NewTicker(t.Interval)
+	ticker := t.Ticker
 	go func() {
 		t.Consumer.Consume(t.Worker().Merkhet(), NewFuture())
 
 		for {
 			select {
-			case <-t.Ticker.C:
+			case <-ticker.C:
 				t.Consumer.Consume(t.Worker().Merkhet(), NewFuture())
-			case <-t.closure:
+			case <-closure:
 				return
 			}
 		}
@@ -75,12 +82,18 @@ func (t *TickedHeartbeat) StartBeating() {
 
 // IsBeating returns if the heartbeat is currently beating
 func (t *TickedHeartbeat) IsBeating() bool {
+	defer t.lock.Unlock()
+
+	t.lock.Lock()
 	return t.closure != nil
 }
 
 // StopBeating stops the heartbeats go routine
 func (t *TickedHeartbeat) StopBeating() {
-	if t.Ticker == nil {
+	defer t.lock.Unlock()
+
+	t.lock.Lock()
+	if t.Ticker == nil || t.closure == nil {
 		return
 	}
 
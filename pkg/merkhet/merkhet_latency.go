@@ -0,0 +1,172 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Timing contains the phases of a single http request as measured with httptrace.
+// Phases that did not happen, eg: the tls handshake of a plain http request or the dns lookup of a reused
+// connection, are zero
+type Timing struct {
+	DNS       time.Duration `json:"dns" yaml:"dns"`
+	Connect   time.Duration `json:"connect" yaml:"connect"`
+	TLS       time.Duration `json:"tls" yaml:"tls"`
+	FirstByte time.Duration `json:"first-byte" yaml:"first-byte"`
+	Total     time.Duration `json:"total" yaml:"total"`
+}
+
+// Latency contains the percentiles of the latencies of a set of samples
+type Latency struct {
+	P50 time.Duration
+	P95 time.Duration
+	P99 time.Duration
+}
+
+// NewLatency computes the 50th, 95th and 99th percentile of the latencies of the samples
+func NewLatency(samples []Sample) Latency {
+	return Latency{
+		P50: Percentile(samples, 50),
+		P95: Percentile(samples, 95),
+		P99: Percentile(samples, 99),
+	}
+}
+
+// Percentile returns the latency below or equal to which the given percentage of the runs completed, using the
+// nearest-rank method. The histograms of aggregated samples are merged, the runs within a bucket are assumed to be
+// spread evenly between the lowest and highest latency of the bucket. It returns zero if there are no runs
+func Percentile(samples []Sample, percentile float64) time.Duration {
+	ranges := make([]latencyRange, 0, len(samples))
+	histogram := &Aggregate{
+		Latencies:    make([]int, len(LatencyBuckets)+1),
+		MinLatencies: make([]time.Duration, len(LatencyBuckets)+1),
+		MaxLatencies: make([]time.Duration, len(LatencyBuckets)+1),
+	}
+
+	total := 0
+	for _, sample := range samples {
+		if sample.Aggregate != nil {
+			histogram.merge(sample.Aggregate)
+			total += sample.Aggregate.Runs
+			continue
+		}
+
+		ranges = append(ranges, latencyRange{min: sample.Latency(), max: sample.Latency(), count: 1})
+		total++
+	}
+
+	for i, count := range histogram.Latencies {
+		if count > 0 {
+			ranges = append(ranges, latencyRange{min: histogram.MinLatencies[i], max: histogram.MaxLatencies[i], count: count})
+		}
+	}
+
+	if len(ranges) < 1 {
+		return 0
+	}
+
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i].max < ranges[j].max })
+
+	rank := int(math.Ceil(percentile / 100 * float64(total)))
+	if rank < 1 {
+		rank = 1
+	}
+
+	for _, r := range ranges {
+		if rank <= r.count {
+			return r.at(rank)
+		}
+		rank -= r.count
+	}
+	return ranges[len(ranges)-1].max
+}
+
+// latencyRange contains an amount of runs whose latencies are spread evenly between the lowest and highest latency
+type latencyRange struct {
+	min   time.Duration
+	max   time.Duration
+	count int
+}
+
+// at returns the latency of the run at the given position within the range, starting with one
+func (r latencyRange) at(position int) time.Duration {
+	if r.count < 2 {
+		return r.max
+	}
+	return r.min + time.Duration(float64(r.max-r.min)*float64(position-1)/float64(r.count-1))
+}
+
+// merge adds the histogram of the other aggregate to the histogram of this aggregate
+func (a *Aggregate) merge(other *Aggregate) {
+	for i, count := range other.Latencies {
+		if count < 1 || i >= len(a.Latencies) {
+			continue
+		}
+
+		if a.Latencies[i] == 0 || other.MinLatencies[i] < a.MinLatencies[i] {
+			a.MinLatencies[i] = other.MinLatencies[i]
+		}
+		if other.MaxLatencies[i] > a.MaxLatencies[i] {
+			a.MaxLatencies[i] = other.MaxLatencies[i]
+		}
+		a.Latencies[i] += count
+	}
+}
+
+// LatencyConfiguration is an implementation of the Configuration interface that extends another configuration
+// by a maximum latency a percentile of the samples has to stay below
+type LatencyConfiguration struct {
+	configuration Configuration
+	percentile    float64
+	maxLatency    time.Duration
+}
+
+// Name returns the name stored in the extended configuration
+func (l *LatencyConfiguration) Name() string {
+	return l.configuration.Name()
+}
+
+// ValidRun returns if the samples are valid in the extended configuration and the percentile of their latencies
+// is below the maximum latency
+func (l *LatencyConfiguration) ValidRun(samples []Sample) bool {
+	return l.configuration.ValidRun(samples) && Percentile(samples, l.percentile) < l.maxLatency
+}
+
+// ThresholdAsString returns the threshold of the extended configuration followed by the latency threshold
+func (l *LatencyConfiguration) ThresholdAsString() string {
+	return fmt.Sprintf("%s, p%s < %s", l.configuration.ThresholdAsString(),
+		strconv.FormatFloat(l.percentile, 'f', -1, 64), l.maxLatency)
+}
+
+// NewLatencyConfiguration creates a new configuration that extends the configuration by a latency threshold,
+// eg: a percentile of 99 and a maximum latency of 500ms require 99% of the samples to complete in less than 500ms
+func NewLatencyConfiguration(configuration Configuration, percentile float64, maxLatency time.Duration) *LatencyConfiguration {
+	return &LatencyConfiguration{
+		configuration: configuration,
+		percentile:    percentile,
+		maxLatency:    maxLatency,
+	}
+}
@@ -0,0 +1,84 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+// LogLoss contains the amount of numbered log lines a merkhet expected and actually received.
+// Lines that arrived after a line with a higher sequence number are counted as out of order. Truncated lines could
+// not be evaluated, eg: as they were no longer part of the recent logs, and are neither expected nor lost
+type LogLoss struct {
+	Expected   int `json:"expected" yaml:"expected"`
+	Received   int `json:"received" yaml:"received"`
+	Lost       int `json:"lost" yaml:"lost"`
+	OutOfOrder int `json:"out-of-order" yaml:"out-of-order"`
+	Truncated  int `json:"truncated,omitempty" yaml:"truncated,omitempty"`
+}
+
+// Rate returns the percentage of the expected lines that were lost
+func (l LogLoss) Rate() float64 {
+	if l.Expected < 1 {
+		return 0
+	}
+	return float64(l.Lost) * 100 / float64(l.Expected)
+}
+
+// OutOfOrderRate returns the percentage of the received lines that arrived out of order
+func (l LogLoss) OutOfOrderRate() float64 {
+	if l.Received < 1 {
+		return 0
+	}
+	return float64(l.OutOfOrder) * 100 / float64(l.Received)
+}
+
+// Add returns the sum of both log losses
+func (l LogLoss) Add(other LogLoss) LogLoss {
+	return LogLoss{
+		Expected:   l.Expected + other.Expected,
+		Received:   l.Received + other.Received,
+		Lost:       l.Lost + other.Lost,
+		OutOfOrder: l.OutOfOrder + other.OutOfOrder,
+		Truncated:  l.Truncated + other.Truncated,
+	}
+}
+
+// TotalLogLoss sums up the log losses of the samples. It returns nil if none of the samples measured a log loss
+func TotalLogLoss(samples []Sample) *LogLoss {
+	var total *LogLoss
+	for _, sample := range samples {
+		if sample.LogLoss == nil {
+			continue
+		}
+
+		if total == nil {
+			total = &LogLoss{}
+		}
+		*total = total.Add(*sample.LogLoss)
+	}
+	return total
+}
+
+// TotalInterruptions sums up how often the samples recorded that a log stream had to be reopened
+func TotalInterruptions(samples []Sample) int {
+	total := 0
+	for _, sample := range samples {
+		total += sample.Interruptions
+	}
+	return total
+}
@@ -21,6 +21,7 @@
 package merkhet
 
 import (
+	"io"
 	"sync"
 	"time"
 )
@@ -37,6 +38,10 @@ type HeartbeatConsumer func(heartbeat Heartbeat) (err error)
 //
 // Size returns the current size of the Pool
 //
+// Heartbeats returns all heartbeats managed by the Pool, beating or not
+//
+// BeatingHearts returns the heartbeats that are currently beating
+//
 // ForEach executes the provided function for each Merkhet instance currently managed by the Pool
 //
 // ForEachHeartbeat executes something for each heartbeat instance
@@ -48,6 +53,7 @@ type Pool interface {
 	StartWorker(m Merkhet, duration time.Duration, heartbeat Consumer)
 	StartHeartbeats()
 	Size() uint
+	Heartbeats() (heartbeats []Heartbeat)
 	BeatingHearts() (heartbeats []Heartbeat)
 	ForEach(consumer Consumer) (output ConsumerResult)
 	Shutdown()
@@ -83,6 +89,13 @@ func (s *SimplePool) Size() uint {
 	return uint(len(s.heartbeats))
 }
 
+// Heartbeats returns all heartbeats managed by the pool
+func (s *SimplePool) Heartbeats() (heartbeats []Heartbeat) {
+	result := make([]Heartbeat, len(s.heartbeats))
+	copy(result, s.heartbeats)
+	return result
+}
+
 // BeatingHearts returns the currently beating hearts
 func (s *SimplePool) BeatingHearts() (heartbeats []Heartbeat) {
 	result := make([]Heartbeat, 0)
@@ -111,7 +124,8 @@ func (s *SimplePool) ForEach(c Consumer) (output ConsumerResult) {
 	return result
 }
 
-// Shutdown shuts the pool and it's heartbeats down
+// Shutdown shuts the pool and it's heartbeats down.
+// Merkhets implementing the io.Closer interface are closed once their heartbeats stopped
 func (s *SimplePool) Shutdown() {
 	for _, beat := range s.heartbeats {
 		beat.StopBeating()
@@ -119,6 +133,9 @@ func (s *SimplePool) Shutdown() {
 	s.TaskWaitGroup().Wait()
 	for _, beat := range s.heartbeats {
 		close(beat.Worker().ControllerChannel().C)
+		if closer, ok := beat.Worker().Merkhet().(io.Closer); ok {
+			_ = closer.Close()
+		}
 	}
 }
 
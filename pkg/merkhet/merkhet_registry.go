@@ -0,0 +1,165 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	// DefaultRegistry is the registry merkhet types register themselves at, typically from an init function
+	DefaultRegistry = NewRegistry()
+
+	yamlLineRegex = regexp.MustCompile("^line [0-9]+: ")
+)
+
+// Settings contains the merkhet type specific settings of a merkhet configuration
+type Settings map[string]interface{}
+
+// Decode decodes the settings into the target structure. Unknown settings and mismatching types are an error
+func (s Settings) Decode(target interface{}) error {
+	if len(s) == 0 {
+		return nil
+	}
+
+	content, err := yaml.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	// the line numbers of the yaml errors refer to the marshalled settings, which would only confuse the user
+	if err := yaml.UnmarshalStrict(content, target); err != nil {
+		if typeError, ok := err.(*yaml.TypeError); ok {
+			messages := make([]string, len(typeError.Errors))
+			for i, message := range typeError.Errors {
+				messages[i] = yamlLineRegex.ReplaceAllString(message, "")
+			}
+			return fmt.Errorf("%s", strings.Join(messages, ", "))
+		}
+		return err
+	}
+	return nil
+}
+
+// FactoryContext contains everything a factory needs to create a merkhet instance
+type FactoryContext struct {
+	Base         Base
+	Settings     Settings
+	Heartbeat    time.Duration
+	Dependencies Dependencies
+}
+
+// Factory creates a new merkhet instance from the given context and returns an error if the settings are invalid.
+// The factory should not have any side effects, setting up the merkhet is the job of Install and PostConnect.
+// Factories are also called to validate the settings of a configuration, the context then only contains the settings
+// and the heartbeat
+type Factory func(context FactoryContext) (Merkhet, error)
+
+// Definition describes a merkhet type that can be configured by its name
+type Definition struct {
+	Name             string
+	DefaultHeartbeat time.Duration
+	Factory          Factory
+}
+
+// Registry contains all merkhet types known to watchful
+//
+// Register adds the definition to the registry. Registering the same name twice is an error
+//
+// Lookup returns the definition registered under the given name
+//
+// Names returns the sorted names of all registered definitions
+type Registry interface {
+	Register(definition Definition) error
+	Lookup(name string) (Definition, bool)
+	Names() []string
+}
+
+// SimpleRegistry is a simple map based implementation of the Registry interface
+type SimpleRegistry struct {
+	definitions map[string]Definition
+	lock        *sync.RWMutex
+}
+
+// NewRegistry creates a new empty registry
+func NewRegistry() *SimpleRegistry {
+	return &SimpleRegistry{
+		definitions: make(map[string]Definition),
+		lock:        &sync.RWMutex{},
+	}
+}
+
+// Register adds the definition to the registry
+func (r *SimpleRegistry) Register(definition Definition) error {
+	if len(definition.Name) < 1 {
+		return fmt.Errorf("merkhet definitions require a name")
+	}
+
+	if definition.Factory == nil {
+		return fmt.Errorf("merkhet definition %s has no factory", definition.Name)
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if _, ok := r.definitions[definition.Name]; ok {
+		return fmt.Errorf("merkhet %s is already registered", definition.Name)
+	}
+
+	r.definitions[definition.Name] = definition
+	return nil
+}
+
+// Lookup returns the definition registered under the given name
+func (r *SimpleRegistry) Lookup(name string) (Definition, bool) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	definition, ok := r.definitions[name]
+	return definition, ok
+}
+
+// Names returns the sorted names of all registered definitions
+func (r *SimpleRegistry) Names() []string {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	names := make([]string, 0, len(r.definitions))
+	for name := range r.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Register adds the definition to the default registry and panics if that fails
+func Register(definition Definition) {
+	if err := DefaultRegistry.Register(definition); err != nil {
+		panic(err)
+	}
+}
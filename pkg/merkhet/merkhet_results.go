@@ -31,24 +31,38 @@ package merkhet
 // TotalRuns returns the total amount of runs this merkhet had
 //
 // Valid returns if the result was marked valid by the Merkhet instance that build it
+//
+// Samples returns the timeline of samples the merkhet instance recorded, ordered by their recording
+//
+// Downtime returns the outage windows derived from the recorded samples
+//
+// Targets returns the targets the merkhet measured separately, which is empty for merkhets that measure a single target
+//
+// ForTarget returns the result that only covers the samples of the given target
 type Result interface {
 	SuccessfulRuns() int
 	FailedRuns() int
 	TotalRuns() int
 	Valid() bool
+	Samples() []Sample
+	Downtime() Downtime
+	Targets() []string
+	ForTarget(target string) Result
 }
 
 // SimpleResult is a small implementation of the Result interface
 type SimpleResult struct {
-	successful int
-	fails      int
-	valid      bool
+	samples []Sample
+	runs    int
+	fails   int
+	valid   bool
+	targets map[string]*SimpleResult
 }
 
 // SuccessfulRuns returns the total amount of runs the merkhet instance ran
 // at the time this result instance was created
 func (s *SimpleResult) SuccessfulRuns() int {
-	return s.successful
+	return s.runs - s.fails
 }
 
 // FailedRuns returns the total amount of failed runs the merkhet instance that build
@@ -59,7 +73,7 @@ func (s *SimpleResult) FailedRuns() int {
 
 // TotalRuns returns the total amount of runs this merkhet had
 func (s *SimpleResult) TotalRuns() int {
-	return s.successful + s.fails
+	return s.runs
 }
 
 // Valid returns if the result was marked valid by the Merkhet instance that build it
@@ -67,11 +81,59 @@ func (s *SimpleResult) Valid() bool {
 	return s.valid
 }
 
-// NewMerkhetResult creates a new instance of the MerkhetResult interface
-func NewMerkhetResult(successfulRuns int, failedRuns int, valid bool) *SimpleResult {
+// Samples returns the timeline of samples the merkhet instance recorded, ordered by their recording
+func (s *SimpleResult) Samples() []Sample {
+	return s.samples
+}
+
+// Downtime returns the outage windows derived from the recorded samples
+func (s *SimpleResult) Downtime() Downtime {
+	return NewDowntime(s.samples)
+}
+
+// Targets returns the targets the merkhet measured separately
+func (s *SimpleResult) Targets() []string {
+	return TargetsOf(s.samples)
+}
+
+// ForTarget returns the result that only covers the samples of the given target
+func (s *SimpleResult) ForTarget(target string) Result {
+	if result, ok := s.targets[target]; ok {
+		return result
+	}
+	return NewMerkhetResult(SamplesOfTarget(s.samples, target), s.valid)
+}
+
+// NewMerkhetResult creates a new instance of the MerkhetResult interface.
+// The results of the targets are considered as valid as the result itself
+func NewMerkhetResult(samples []Sample, valid bool) *SimpleResult {
 	return &SimpleResult{
-		successful: successfulRuns,
-		fails:      failedRuns,
-		valid:      valid,
+		samples: samples,
+		runs:    CountRuns(samples),
+		fails:   CountFailures(samples),
+		valid:   valid,
+		targets: make(map[string]*SimpleResult),
+	}
+}
+
+// NewEvaluatedResult creates a new result whose validity is decided by the configuration.
+// If the samples measured several targets, every target is checked on its own and the result is only valid
+// if all of them are valid
+func NewEvaluatedResult(configuration Configuration, samples []Sample) *SimpleResult {
+	targets := TargetsOf(samples)
+	if len(targets) < 1 {
+		return NewMerkhetResult(samples, configuration.ValidRun(samples))
+	}
+
+	result := NewMerkhetResult(samples, true)
+	if untargeted := SamplesOfTarget(samples, ""); len(untargeted) > 0 {
+		result.valid = configuration.ValidRun(untargeted)
+	}
+
+	for _, target := range targets {
+		targetSamples := SamplesOfTarget(samples, target)
+		result.targets[target] = NewMerkhetResult(targetSamples, configuration.ValidRun(targetSamples))
+		result.valid = result.valid && result.targets[target].valid
 	}
+	return result
 }
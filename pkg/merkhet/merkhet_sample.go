@@ -0,0 +1,188 @@
+// Copyright © 2019 The Homeport Team
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in
+// all copies or substantial portions of the Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN
+// THE SOFTWARE.
+
+package merkhet
+
+import (
+	"time"
+)
+
+// UnknownError is the error message of a failed sample whose error did not contain a message
+const UnknownError = "failed without an error message"
+
+// Sample is a single recorded outcome of a merkhet execution
+type Sample struct {
+	Start         time.Time     `json:"start" yaml:"start"`
+	Duration      time.Duration `json:"duration" yaml:"duration"`
+	Error         string        `json:"error,omitempty" yaml:"error,omitempty"`
+	TaskIndex     int           `json:"task-index" yaml:"task-index"`
+	Target        string        `json:"target,omitempty" yaml:"target,omitempty"`
+	Timing        *Timing       `json:"timing,omitempty" yaml:"timing,omitempty"`
+	Instance      *int          `json:"instance,omitempty" yaml:"instance,omitempty"`
+	LogLoss       *LogLoss      `json:"log-loss,omitempty" yaml:"log-loss,omitempty"`
+	Aggregate     *Aggregate    `json:"aggregate,omitempty" yaml:"aggregate,omitempty"`
+	Disconnects   []Disconnect  `json:"disconnects,omitempty" yaml:"disconnects,omitempty"`
+	Interruptions int           `json:"interruptions,omitempty" yaml:"interruptions,omitempty"`
+}
+
+// NewSample creates a new sample that started at the given time and took the given duration.
+// The sample is considered failed if the passed error is not nil, even if the error has an empty message
+func NewSample(start time.Time, duration time.Duration, err error) Sample {
+	sample := Sample{
+		Start:    start,
+		Duration: duration,
+	}
+
+	if err != nil {
+		sample.Error = err.Error()
+		if len(sample.Error) == 0 {
+			sample.Error = UnknownError
+		}
+	}
+	return sample
+}
+
+// NewTargetSample creates a new sample of a merkhet that measures several targets on a single execution
+func NewTargetSample(target string, start time.Time, duration time.Duration, err error) Sample {
+	sample := NewSample(start, duration, err)
+	sample.Target = target
+	return sample
+}
+
+// Failed returns if the sample recorded a failed execution
+func (s Sample) Failed() bool {
+	return len(s.Error) > 0
+}
+
+// Runs returns the amount of runs the sample covers, which is one unless the sample aggregates several runs
+func (s Sample) Runs() int {
+	if s.Aggregate != nil {
+		return s.Aggregate.Runs
+	}
+	return 1
+}
+
+// FailedRuns returns the amount of failed runs the sample covers
+func (s Sample) FailedRuns() int {
+	switch {
+	case s.Aggregate != nil:
+		return s.Aggregate.Failures
+	case s.Failed():
+		return 1
+	default:
+		return 0
+	}
+}
+
+// Latency returns the total duration of the measured request if the sample contains a timing,
+// otherwise the duration of the execution
+func (s Sample) Latency() time.Duration {
+	if s.Timing != nil {
+		return s.Timing.Total
+	}
+	return s.Duration
+}
+
+// End returns the point in time the execution of the sample ended
+func (s Sample) End() time.Time {
+	return s.Start.Add(s.Duration)
+}
+
+// CountFailures returns the amount of failed runs of the samples in the given slice
+func CountFailures(samples []Sample) int {
+	failures := 0
+	for _, sample := range samples {
+		failures += sample.FailedRuns()
+	}
+	return failures
+}
+
+// CountSkipped returns the amount of runs the samples in the given slice skipped
+func CountSkipped(samples []Sample) int {
+	skipped := 0
+	for _, sample := range samples {
+		if sample.Aggregate != nil {
+			skipped += sample.Aggregate.Skipped
+		}
+	}
+	return skipped
+}
+
+// CountRuns returns the amount of runs of the samples in the given slice
+func CountRuns(samples []Sample) int {
+	runs := 0
+	for _, sample := range samples {
+		runs += sample.Runs()
+	}
+	return runs
+}
+
+// SamplesOfTask returns all samples that were recorded while the task with the given index was executed
+func SamplesOfTask(samples []Sample, taskIndex int) []Sample {
+	result := make([]Sample, 0)
+	for _, sample := range samples {
+		if sample.TaskIndex == taskIndex {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// SamplesOfTarget returns all samples that measured the given target
+func SamplesOfTarget(samples []Sample, target string) []Sample {
+	result := make([]Sample, 0)
+	for _, sample := range samples {
+		if sample.Target == target {
+			result = append(result, sample)
+		}
+	}
+	return result
+}
+
+// TargetsOf returns the distinct targets of the given samples in the order they were first recorded.
+// Samples without a target are ignored
+func TargetsOf(samples []Sample) []string {
+	seen := make(map[string]bool)
+	targets := make([]string, 0)
+	for _, sample := range samples {
+		if len(sample.Target) > 0 && !seen[sample.Target] {
+			seen[sample.Target] = true
+			targets = append(targets, sample.Target)
+		}
+	}
+	return targets
+}
+
+// InstanceDistribution counts the runs per app instance that served them. Runs without an instance are ignored
+func InstanceDistribution(samples []Sample) map[int]int {
+	distribution := make(map[int]int)
+	for _, sample := range samples {
+		if sample.Instance != nil {
+			distribution[*sample.Instance]++
+		}
+
+		if sample.Aggregate != nil {
+			for instance, runs := range sample.Aggregate.Instances {
+				distribution[instance] += runs
+			}
+		}
+	}
+	return distribution
+}
@@ -21,6 +21,7 @@
 package merkhet_test
 
 import (
+	"fmt"
 	"time"
 
 	. "github.com/onsi/ginkgo"
@@ -121,6 +122,250 @@ var _ = Describe("Merkhet code test", func() {
 			close(done)
 		}, 10*1000)
 
+		It("should record samples with their timing and task index", func() {
+			start := time.Now()
+			merkhet.Base().StartTask(1)
+			merkhet.Base().Record(NewSample(start, time.Second, nil))
+			merkhet.Base().StartTask(2)
+			merkhet.Base().Record(NewSample(start.Add(time.Second), 2*time.Second, fmt.Errorf("connection refused")))
+
+			result := merkhet.Base().NewResultSet()
+			Expect(result.TotalRuns()).To(BeEquivalentTo(2))
+			Expect(result.FailedRuns()).To(BeEquivalentTo(1))
+			Expect(result.SuccessfulRuns()).To(BeEquivalentTo(1))
+
+			samples := result.Samples()
+			Expect(samples[0].Start).To(Equal(start))
+			Expect(samples[0].Duration).To(Equal(time.Second))
+			Expect(samples[0].Failed()).To(BeFalse())
+			Expect(samples[0].TaskIndex).To(BeEquivalentTo(1))
+
+			Expect(samples[1].End()).To(Equal(start.Add(3 * time.Second)))
+			Expect(samples[1].Error).To(BeEquivalentTo("connection refused"))
+			Expect(samples[1].TaskIndex).To(BeEquivalentTo(2))
+
+			Expect(SamplesOfTask(samples, 2)).To(HaveLen(1))
+		})
+
+		It("should notify observers about recorded and future samples", func() {
+			base := NewSimpleBase(nil, NewFlatConfiguration("test-config", 0))
+			base.RecordSuccessfulRun()
+
+			observed := make([]Sample, 0)
+			base.Observe(func(sample Sample) { observed = append(observed, sample) })
+			base.StartTask(2)
+			base.RecordFailedRun()
+
+			Expect(observed).To(HaveLen(2))
+			Expect(observed[0].Failed()).To(BeFalse())
+			Expect(observed[1].Failed()).To(BeTrue())
+			Expect(observed[1].TaskIndex).To(BeEquivalentTo(2))
+		})
+
+		It("should record an error without a message as a failure", func() {
+			sample := NewSample(time.Now(), time.Second, fmt.Errorf(""))
+			Expect(sample.Failed()).To(BeTrue())
+			Expect(sample.Error).To(BeEquivalentTo(UnknownError))
+		})
+
+		It("should detect contiguous outage windows", func() {
+			start := time.Now()
+			sample := func(offset int, failed bool, task int) Sample {
+				var err error
+				if failed {
+					err = fmt.Errorf("failed")
+				}
+				s := NewSample(start.Add(time.Duration(offset)*time.Second), 500*time.Millisecond, err)
+				s.TaskIndex = task
+				return s
+			}
+
+			downtime := NewDowntime([]Sample{
+				sample(0, false, 1),
+				sample(2, true, 1),
+				sample(1, true, 1),
+				sample(3, false, 1),
+				sample(4, true, 1),
+				sample(5, true, 2),
+				sample(6, false, 2),
+			})
+
+			Expect(downtime.Outages).To(HaveLen(3))
+			Expect(downtime.Outages[0].Failures).To(BeEquivalentTo(2))
+			Expect(downtime.Outages[0].Duration()).To(Equal(2 * time.Second))
+			Expect(downtime.Outages[1].Duration()).To(Equal(500 * time.Millisecond))
+			Expect(downtime.Outages[2].Duration()).To(Equal(time.Second))
+
+			Expect(downtime.FirstFailure()).To(Equal(start.Add(time.Second)))
+			Expect(downtime.Outages[2].End).To(Equal(start.Add(6 * time.Second)))
+			Expect(downtime.LastFailure()).To(Equal(start.Add(5500 * time.Millisecond)))
+			Expect(downtime.Total()).To(Equal(3500 * time.Millisecond))
+			Expect(downtime.Longest()).To(Equal(2 * time.Second))
+		})
+
+		It("should not detect outages without failed samples", func() {
+			downtime := merkhet.Base().NewResultSet().Downtime()
+			Expect(downtime.Outages).To(BeEmpty())
+			Expect(downtime.Total()).To(BeZero())
+			Expect(downtime.FirstFailure().IsZero()).To(BeTrue())
+		})
+
+		It("should detect the outages of every target separately", func() {
+			start := time.Now()
+			downtime := NewDowntime([]Sample{
+				NewTargetSample("a", start, time.Second, fmt.Errorf("failed")),
+				NewTargetSample("b", start, time.Second, nil),
+				NewTargetSample("a", start.Add(time.Second), time.Second, nil),
+				NewTargetSample("b", start.Add(time.Second), time.Second, fmt.Errorf("failed")),
+				NewTargetSample("b", start.Add(2*time.Second), time.Second, fmt.Errorf("failed")),
+			})
+
+			Expect(downtime.Outages).To(HaveLen(2))
+			Expect(downtime.Outages[0].Target).To(BeEquivalentTo("a"))
+			Expect(downtime.Outages[0].Duration()).To(Equal(time.Second))
+			Expect(downtime.Outages[1].Target).To(BeEquivalentTo("b"))
+			Expect(downtime.Outages[1].Duration()).To(Equal(2 * time.Second))
+			Expect(downtime.LastFailure()).To(Equal(start.Add(3 * time.Second)))
+		})
+
+		It("should evaluate every target on its own", func() {
+			start := time.Now()
+			samples := []Sample{
+				NewTargetSample("a", start, time.Second, nil),
+				NewTargetSample("b", start, time.Second, fmt.Errorf("failed")),
+				NewTargetSample("a", start.Add(time.Second), time.Second, fmt.Errorf("failed")),
+				NewTargetSample("b", start.Add(time.Second), time.Second, fmt.Errorf("failed")),
+			}
+
+			result := NewEvaluatedResult(NewFlatConfiguration("test-config", 1), samples)
+			Expect(result.Targets()).To(BeEquivalentTo([]string{"a", "b"}))
+			Expect(result.ForTarget("a").Valid()).To(BeTrue())
+			Expect(result.ForTarget("a").TotalRuns()).To(BeEquivalentTo(2))
+			Expect(result.ForTarget("b").Valid()).To(BeFalse())
+			Expect(result.ForTarget("b").FailedRuns()).To(BeEquivalentTo(2))
+			Expect(result.Valid()).To(BeFalse())
+			Expect(result.FailedRuns()).To(BeEquivalentTo(3))
+
+			Expect(NewEvaluatedResult(NewFlatConfiguration("test-config", 2), samples).Valid()).To(BeTrue())
+		})
+
+		It("should count the samples served by every instance", func() {
+			first, second := 0, 1
+			samples := []Sample{
+				NewSample(time.Now(), time.Second, nil),
+				NewSample(time.Now(), time.Second, nil),
+				NewSample(time.Now(), time.Second, fmt.Errorf("failed")),
+				NewSample(time.Now(), time.Second, nil),
+			}
+			samples[0].Instance = &first
+			samples[1].Instance = &second
+			samples[3].Instance = &first
+
+			Expect(InstanceDistribution(samples)).To(BeEquivalentTo(map[int]int{0: 2, 1: 1}))
+			Expect(InstanceDistribution(samples[2:3])).To(BeEmpty())
+		})
+
+		It("should sum up the log loss of samples", func() {
+			samples := []Sample{
+				NewSample(time.Now(), time.Second, nil),
+				NewSample(time.Now(), time.Second, nil),
+				NewSample(time.Now(), time.Second, fmt.Errorf("failed")),
+			}
+			Expect(TotalLogLoss(samples)).To(BeNil())
+
+			samples[0].LogLoss = &LogLoss{Expected: 10, Received: 9, Lost: 1}
+			samples[2].LogLoss = &LogLoss{Expected: 10, Received: 7, Lost: 3, OutOfOrder: 2}
+
+			total := TotalLogLoss(samples)
+			Expect(total).To(BeEquivalentTo(&LogLoss{Expected: 20, Received: 16, Lost: 4, OutOfOrder: 2}))
+			Expect(total.Rate()).To(BeNumerically("==", 20))
+			Expect(total.OutOfOrderRate()).To(BeNumerically("==", 12.5))
+			Expect(LogLoss{}.Rate()).To(BeZero())
+
+			samples[1].Interruptions = 2
+			samples[2].Interruptions = 1
+			Expect(TotalInterruptions(samples)).To(BeEquivalentTo(3))
+		})
+
+		It("should merge the disconnects recorded across samples", func() {
+			start := time.Now()
+			first := Disconnect{Connection: 1, Time: start.Add(time.Second), Reason: "EOF"}
+			second := Disconnect{Connection: 0, Time: start, Reason: "connection reset"}
+
+			samples := []Sample{
+				NewSample(start, time.Second, fmt.Errorf("dropped")),
+				NewSample(start.Add(2*time.Second), time.Second, nil),
+			}
+			samples[0].Disconnects = []Disconnect{first, second}
+			reconnected := first
+			reconnected.Reconnected = start.Add(3 * time.Second)
+			samples[1].Disconnects = []Disconnect{reconnected}
+
+			disconnects := DisconnectsOf(samples)
+			Expect(disconnects).To(HaveLen(2))
+			Expect(disconnects[0]).To(BeEquivalentTo(second))
+			Expect(disconnects[0].ReconnectTime()).To(BeZero())
+			Expect(disconnects[1].Connection).To(BeEquivalentTo(1))
+			Expect(disconnects[1].ReconnectTime()).To(BeEquivalentTo(2 * time.Second))
+			Expect(DisconnectsOf(samples[1:1])).To(BeEmpty())
+		})
+
+		It("should aggregate many runs into a single sample", func() {
+			start := time.Now()
+			first, second := 0, 1
+			runs := []Sample{
+				NewSample(start, 10*time.Millisecond, nil),
+				NewSample(start.Add(time.Second), 10*time.Millisecond, fmt.Errorf("status 502")),
+				NewSample(start.Add(2*time.Second), 10*time.Millisecond, fmt.Errorf("status 503")),
+				NewSample(start.Add(3*time.Second), 3*time.Second, nil),
+			}
+			runs[0].Instance = &first
+			runs[3].Instance = &second
+
+			sample := NewAggregateSample("", start, 4*time.Second, runs)
+			Expect(sample.Runs()).To(BeEquivalentTo(4))
+			Expect(sample.FailedRuns()).To(BeEquivalentTo(2))
+			Expect(sample.Error).To(BeEquivalentTo("2 of 4 runs failed, first error: status 502"))
+			Expect(sample.Aggregate.FirstFailure).To(Equal(start.Add(time.Second)))
+			Expect(sample.Aggregate.LastFailure).To(Equal(start.Add(2*time.Second + 10*time.Millisecond)))
+			Expect(sample.Aggregate.Recovery).To(Equal(start.Add(3 * time.Second)))
+			Expect(InstanceDistribution([]Sample{sample})).To(BeEquivalentTo(map[int]int{0: 1, 1: 1}))
+
+			result := NewMerkhetResult([]Sample{sample, NewSample(start.Add(5*time.Second), time.Second, nil)}, true)
+			Expect(result.TotalRuns()).To(BeEquivalentTo(5))
+			Expect(result.FailedRuns()).To(BeEquivalentTo(2))
+			Expect(result.Downtime().Outages).To(HaveLen(1))
+			Expect(result.Downtime().Total()).To(Equal(2 * time.Second))
+			Expect(result.Downtime().LastFailure()).To(Equal(sample.Aggregate.LastFailure))
+
+			Expect(Percentile([]Sample{sample}, 50)).To(Equal(10 * time.Millisecond))
+			Expect(Percentile([]Sample{sample}, 99)).To(Equal(3 * time.Second))
+			Expect(NewPercentageConfiguration("test-config", 0.5).ValidRun([]Sample{sample})).To(BeTrue())
+			Expect(NewPercentageConfiguration("test-config", 0.25).ValidRun([]Sample{sample})).To(BeFalse())
+		})
+
+		It("should compute the percentiles of aggregated runs from the measured latencies", func() {
+			start := time.Now()
+			runs := make([]Sample, 0, 100)
+			for i := 0; i < 99; i++ {
+				runs = append(runs, NewSample(start, 300*time.Millisecond, nil))
+			}
+			runs = append(runs, NewSample(start, 2*time.Second, nil))
+
+			sample := NewAggregateSample("", start, time.Second, runs)
+			Expect(Percentile([]Sample{sample}, 99)).To(Equal(300 * time.Millisecond))
+			Expect(Percentile([]Sample{sample}, 100)).To(Equal(2 * time.Second))
+			Expect(NewLatencyConfiguration(NewFlatConfiguration("test-config", 0), 99, 500*time.Millisecond).ValidRun([]Sample{sample})).To(BeTrue())
+
+			spread := NewAggregateSample("", start, time.Second, []Sample{
+				NewSample(start, 210*time.Millisecond, nil),
+				NewSample(start, 250*time.Millisecond, nil),
+				NewSample(start, 290*time.Millisecond, nil),
+			})
+			Expect(Percentile([]Sample{spread, sample}, 0.5)).To(Equal(210 * time.Millisecond))
+			Expect(Percentile([]Sample{spread}, 50)).To(Equal(250 * time.Millisecond))
+		})
+
 		It("should pass the merkhet test using a flat config", func() {
 			merkhet = NewMerkhetMock(NewFlatConfiguration("test-config", 2), 10, 2, true, callback)
 			Expect(merkhet.Base().NewResultSet().Valid()).To(BeTrue())
@@ -140,5 +385,103 @@ var _ = Describe("Merkhet code test", func() {
 			merkhet = NewMerkhetMock(NewPercentageConfiguration("test-config", 0.1), 10, 2, true, callback)
 			Expect(merkhet.Base().NewResultSet().Valid()).To(BeFalse())
 		})
+
+		It("should validate outages using a duration config", func() {
+			start := time.Now()
+			samples := []Sample{
+				NewSample(start, time.Second, nil),
+				NewSample(start.Add(1*time.Second), time.Second, fmt.Errorf("failed")),
+				NewSample(start.Add(4*time.Second), time.Second, nil),
+				NewSample(start.Add(5*time.Second), time.Second, fmt.Errorf("failed")),
+				NewSample(start.Add(7*time.Second), time.Second, nil),
+			}
+
+			Expect(NewDurationConfiguration("test-config", 3*time.Second, 0).ValidRun(samples)).To(BeTrue())
+			Expect(NewDurationConfiguration("test-config", 2*time.Second, 0).ValidRun(samples)).To(BeFalse())
+			Expect(NewDurationConfiguration("test-config", 3*time.Second, 5*time.Second).ValidRun(samples)).To(BeTrue())
+			Expect(NewDurationConfiguration("test-config", 3*time.Second, 4*time.Second).ValidRun(samples)).To(BeFalse())
+		})
+
+		It("should render the threshold of a duration config", func() {
+			Expect(NewDurationConfiguration("test-config", 30*time.Second, 0).ThresholdAsString()).To(BeEquivalentTo("30s per outage"))
+			Expect(NewDurationConfiguration("test-config", 30*time.Second, 2*time.Minute).ThresholdAsString()).To(BeEquivalentTo("30s per outage, 2m0s in total"))
+		})
+
+		It("should compute the latency percentiles of samples", func() {
+			samples := make([]Sample, 0)
+			for i := 1; i <= 100; i++ {
+				samples = append(samples, NewSample(time.Now(), time.Duration(i)*time.Millisecond, nil))
+			}
+			samples[99].Timing = &Timing{Total: time.Second}
+
+			Expect(NewLatency(samples)).To(BeEquivalentTo(Latency{P50: 50 * time.Millisecond, P95: 95 * time.Millisecond, P99: 99 * time.Millisecond}))
+			Expect(Percentile(samples, 100)).To(BeEquivalentTo(time.Second))
+			Expect(Percentile(nil, 99)).To(BeEquivalentTo(0))
+		})
+
+		It("should validate latencies using a latency config", func() {
+			samples := []Sample{
+				NewSample(time.Now(), 100*time.Millisecond, nil),
+				NewSample(time.Now(), 200*time.Millisecond, nil),
+				NewSample(time.Now(), 25*time.Second, nil),
+			}
+
+			Expect(NewLatencyConfiguration(NewFlatConfiguration("test-config", 0), 50, 500*time.Millisecond).ValidRun(samples)).To(BeTrue())
+			Expect(NewLatencyConfiguration(NewFlatConfiguration("test-config", 0), 99, 500*time.Millisecond).ValidRun(samples)).To(BeFalse())
+			Expect(NewLatencyConfiguration(NewFlatConfiguration("test-config", 0), 99, 30*time.Second).ValidRun(samples)).To(BeTrue())
+
+			samples = append(samples, NewSample(time.Now(), time.Millisecond, fmt.Errorf("failed")))
+			Expect(NewLatencyConfiguration(NewFlatConfiguration("test-config", 0), 99, 30*time.Second).ValidRun(samples)).To(BeFalse())
+			Expect(NewLatencyConfiguration(NewFlatConfiguration("test-config", 0), 99.9, 500*time.Millisecond).ThresholdAsString()).
+				To(BeEquivalentTo("0, p99.9 < 500ms"))
+		})
+	})
+
+	Context("Testing the merkhet registry", func() {
+		var registry *SimpleRegistry
+
+		factory := func(context FactoryContext) (Merkhet, error) {
+			return NewMerkhetMock(context.Base.Configuration(), 0, 0, true, &MerkhetCallback{}), nil
+		}
+
+		BeforeEach(func() {
+			registry = NewRegistry()
+		})
+
+		It("should look up registered definitions", func() {
+			Expect(registry.Register(Definition{Name: "b-merkhet", DefaultHeartbeat: time.Second, Factory: factory})).To(Succeed())
+			Expect(registry.Register(Definition{Name: "a-merkhet", DefaultHeartbeat: time.Minute, Factory: factory})).To(Succeed())
+
+			definition, ok := registry.Lookup("a-merkhet")
+			Expect(ok).To(BeTrue())
+			Expect(definition.DefaultHeartbeat).To(BeEquivalentTo(time.Minute))
+
+			_, ok = registry.Lookup("c-merkhet")
+			Expect(ok).To(BeFalse())
+
+			Expect(registry.Names()).To(BeEquivalentTo([]string{"a-merkhet", "b-merkhet"}))
+		})
+
+		It("should reject invalid and duplicate definitions", func() {
+			Expect(registry.Register(Definition{Name: "a-merkhet", Factory: factory})).To(Succeed())
+			Expect(registry.Register(Definition{Name: "a-merkhet", Factory: factory})).NotTo(Succeed())
+			Expect(registry.Register(Definition{Name: "", Factory: factory})).NotTo(Succeed())
+			Expect(registry.Register(Definition{Name: "b-merkhet"})).NotTo(Succeed())
+		})
+
+		It("should decode settings strictly", func() {
+			type settings struct {
+				Timeout time.Duration `yaml:"timeout"`
+				Status  int           `yaml:"status"`
+			}
+
+			decoded := settings{Status: 200}
+			Expect(Settings{"timeout": "5s"}.Decode(&decoded)).To(Succeed())
+			Expect(decoded).To(BeEquivalentTo(settings{Timeout: 5 * time.Second, Status: 200}))
+
+			Expect(Settings(nil).Decode(&decoded)).To(Succeed())
+			Expect(Settings{"timeuot": "5s"}.Decode(&decoded)).NotTo(Succeed())
+			Expect(Settings{"status": "ok"}.Decode(&decoded)).NotTo(Succeed())
+		})
 	})
 })